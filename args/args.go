@@ -1,6 +1,7 @@
 package args
 
 import (
+	"fmt"
 	"strings"
 
 	"github.com/go-task/task/v3/taskfile/ast"
@@ -24,6 +25,21 @@ func Parse(args ...string) ([]*ast.Call, *ast.Vars) {
 	return calls, globals
 }
 
+// ParseCallVars parses variables given in the KEY=VALUE format, e.g. to be used
+// as the variables of a call. Values may contain "=". Every argument must
+// contain a non-empty key followed by "=".
+func ParseCallVars(args []string) (*ast.Vars, error) {
+	vars := &ast.Vars{}
+	for _, arg := range args {
+		if !strings.Contains(arg, "=") || strings.HasPrefix(arg, "=") {
+			return nil, fmt.Errorf(`task: Invalid variable %q. Variables must be given as "KEY=VALUE"`, arg)
+		}
+		name, value := splitVar(arg)
+		vars.Set(name, ast.Var{Value: value})
+	}
+	return vars, nil
+}
+
 func splitVar(s string) (string, string) {
 	pair := strings.SplitN(s, "=", 2)
 	return pair[0], pair[1]
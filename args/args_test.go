@@ -5,6 +5,7 @@ import (
 	"testing"
 
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
 
 	"github.com/go-task/task/v3/args"
 	"github.com/go-task/task/v3/internal/omap"
@@ -106,3 +107,17 @@ func TestArgs(t *testing.T) {
 		})
 	}
 }
+
+func TestParseCallVars(t *testing.T) {
+	t.Parallel()
+
+	vars, err := args.ParseCallVars([]string{"FOO=bar", "QUERY=a=b", "EMPTY="})
+	require.NoError(t, err)
+	assert.Equal(t, []string{"FOO", "QUERY", "EMPTY"}, vars.Keys())
+	assert.Equal(t, []ast.Var{{Value: "bar"}, {Value: "a=b"}, {Value: ""}}, vars.Values())
+
+	for _, arg := range []string{"FOO", "=bar", ""} {
+		_, err := args.ParseCallVars([]string{"FOO=bar", arg})
+		require.EqualError(t, err, fmt.Sprintf(`task: Invalid variable %q. Variables must be given as "KEY=VALUE"`, arg))
+	}
+}
@@ -4,10 +4,8 @@ import (
 	"context"
 	"fmt"
 	"os"
-	"strings"
 
 	"github.com/spf13/pflag"
-	"mvdan.cc/sh/v3/syntax"
 
 	"github.com/go-task/task/v3"
 	"github.com/go-task/task/v3/args"
@@ -108,6 +106,8 @@ func run() error {
 		taskSorter = &sort.AlphaNumeric{}
 	}
 
+	tasksAndVars, cliArgs := getArgs()
+
 	e := task.Executor{
 		Dir:         dir,
 		Entrypoint:  entrypoint,
@@ -127,6 +127,7 @@ func run() error {
 		Color:       flags.Color,
 		Concurrency: flags.Concurrency,
 		Interval:    flags.Interval,
+		CLIArgs:     cliArgs,
 
 		Stdin:  os.Stdin,
 		Stdout: os.Stdout,
@@ -182,11 +183,6 @@ func run() error {
 		globals *ast.Vars
 	)
 
-	tasksAndVars, cliArgs, err := getArgs()
-	if err != nil {
-		return err
-	}
-
 	calls, globals = args.Parse(tasksAndVars...)
 
 	// If there are no calls, run the default task instead
@@ -194,7 +190,6 @@ func run() error {
 		calls = append(calls, &ast.Call{Task: "default"})
 	}
 
-	globals.Set("CLI_ARGS", ast.Var{Value: cliArgs})
 	globals.Set("CLI_FORCE", ast.Var{Value: flags.Force || flags.ForceAll})
 	globals.Set("CLI_SILENT", ast.Var{Value: flags.Silent})
 	globals.Set("CLI_VERBOSE", ast.Var{Value: flags.Verbose})
@@ -214,23 +209,16 @@ func run() error {
 	return e.Run(ctx, calls...)
 }
 
-func getArgs() ([]string, string, error) {
+func getArgs() ([]string, []string) {
 	var (
 		args          = pflag.Args()
 		doubleDashPos = pflag.CommandLine.ArgsLenAtDash()
 	)
 
+	// The CLI arguments are always set, so that they override a CLI_ARGS
+	// variable declared in the Taskfile even when there are none
 	if doubleDashPos == -1 {
-		return args, "", nil
-	}
-
-	var quotedCliArgs []string
-	for _, arg := range args[doubleDashPos:] {
-		quotedCliArg, err := syntax.Quote(arg, syntax.LangBash)
-		if err != nil {
-			return nil, "", err
-		}
-		quotedCliArgs = append(quotedCliArgs, quotedCliArg)
+		return args, []string{}
 	}
-	return args[:doubleDashPos], strings.Join(quotedCliArgs, " "), nil
+	return args[:doubleDashPos], args[doubleDashPos:]
 }
@@ -0,0 +1,124 @@
+package task
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"regexp"
+
+	"mvdan.cc/sh/v3/syntax"
+
+	"github.com/go-task/task/v3/taskfile/ast"
+)
+
+// VarsDumper writes variables to w in a given format.
+type VarsDumper func(w io.Writer, vars *ast.Vars) error
+
+// varsDumpers are the formats of DumpVars available by default.
+var varsDumpers = map[string]VarsDumper{
+	"env":  dumpEnvVars,
+	"json": dumpJSONVars,
+}
+
+// envVarNameRegex matches the names of the variables that can be written in
+// the env format.
+var envVarNameRegex = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_]*$`)
+
+// DumpVars writes the variables of the task of call, resolved as they are
+// when running it, to w in the given format: "env" for KEY=VALUE lines that a
+// shell can source, "json" for a JSON object, or one of VarsDumpFormats. If
+// call is nil, only the global variables are written. Lazy dynamic variables
+// are resolved and environment variables are left out. Unless DumpSecretVars
+// is set, secrets are masked the same way they are in the output of Task.
+func (e *Executor) DumpVars(call *ast.Call, w io.Writer, format string) error {
+	dump, ok := e.VarsDumpFormats[format]
+	if !ok {
+		dump, ok = varsDumpers[format]
+	}
+	if !ok {
+		return fmt.Errorf(`task: Unknown variables format "%s"`, format)
+	}
+
+	vars, err := e.resolveVars(call)
+	if err != nil {
+		return err
+	}
+	if err := e.Compiler.ResolveLazyVars(context.Background(), vars, vars.Keys()...); err != nil {
+		return err
+	}
+	dumped := &ast.Vars{}
+	_ = vars.Range(func(k string, v ast.Var) error {
+		if v.Source == ast.VarSourceEnviron {
+			return nil
+		}
+		if !e.DumpSecretVars {
+			if v.Secret {
+				v.Value = "***"
+			} else {
+				v.Value = e.redactValue(v.Value)
+			}
+		}
+		dumped.Set(k, v)
+		return nil
+	})
+	return dump(w, dumped)
+}
+
+// redactValue masks the secrets in value and in the items of lists and maps.
+func (e *Executor) redactValue(value any) any {
+	switch value := value.(type) {
+	case string:
+		return e.Logger.Redact(value)
+	case []string:
+		redacted := make([]string, len(value))
+		for i, item := range value {
+			redacted[i] = e.Logger.Redact(item)
+		}
+		return redacted
+	case []any:
+		redacted := make([]any, len(value))
+		for i, item := range value {
+			redacted[i] = e.redactValue(item)
+		}
+		return redacted
+	case map[string]any:
+		redacted := make(map[string]any, len(value))
+		for k, item := range value {
+			redacted[k] = e.redactValue(item)
+		}
+		return redacted
+	default:
+		return value
+	}
+}
+
+// dumpEnvVars writes a KEY=VALUE line for each variable, with the value quoted
+// for a POSIX shell. Values that are not strings are formatted the same way
+// they are when used in a template. Variables whose name can't be the name of
+// a shell variable (e.g. "MY-VAR") are left out.
+func dumpEnvVars(w io.Writer, vars *ast.Vars) error {
+	return vars.Range(func(k string, v ast.Var) error {
+		if !envVarNameRegex.MatchString(k) {
+			return nil
+		}
+		value, err := syntax.Quote(formatVarValue(v.Value), syntax.LangPOSIX)
+		if err != nil {
+			return fmt.Errorf(`task: Failed to write variable "%s": %w`, k, err)
+		}
+		_, err = fmt.Fprintf(w, "%s=%s\n", k, value)
+		return err
+	})
+}
+
+// dumpJSONVars writes the variables as an indented JSON object.
+func dumpJSONVars(w io.Writer, vars *ast.Vars) error {
+	values := make(map[string]any, vars.Len())
+	_ = vars.Range(func(k string, v ast.Var) error {
+		values[k] = v.Value
+		return nil
+	})
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	return enc.Encode(values)
+}
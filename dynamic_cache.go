@@ -0,0 +1,53 @@
+package task
+
+import (
+	"os"
+
+	"github.com/go-task/task/v3/internal/filepathext"
+)
+
+const dynamicCacheFileName = "dynamic-vars.json"
+
+func (e *Executor) dynamicCachePath() string {
+	return filepathext.SmartJoin(e.TempDir.Fingerprint, dynamicCacheFileName)
+}
+
+// LoadDynamicCache loads the results of dynamic variables persisted by a
+// previous run. It is called automatically by Setup.
+func (e *Executor) LoadDynamicCache() error {
+	return e.Compiler.LoadDynamicCache(e.dynamicCachePath())
+}
+
+// SaveDynamicCache persists the results of dynamic variables marked with
+// "persist" that were resolved by this run, so they can be reused by the next
+// one. Results persisted by previous runs and not used since are dropped. It
+// is called automatically at the end of Run. Nothing is saved in dry mode.
+func (e *Executor) SaveDynamicCache() error {
+	if e.Dry {
+		return nil
+	}
+	return e.Compiler.SaveDynamicCache(e.dynamicCachePath())
+}
+
+// ResetDynamicCache clears the cached results of dynamic variables, so their
+// commands run again the next time they are resolved, e.g. after the state
+// they depend on changed. Results persisted by previous runs are removed from
+// disk too, except in dry mode. It is safe to call while tasks are running,
+// but a command that is already running still caches its result.
+func (e *Executor) ResetDynamicCache() error {
+	e.Compiler.ResetCache()
+	if e.Dry {
+		return nil
+	}
+	if err := os.Remove(e.dynamicCachePath()); err != nil && !os.IsNotExist(err) {
+		return err
+	}
+	return nil
+}
+
+// InvalidateVar removes the cached results of the dynamic variables running
+// the given command, as run after its templates are rendered. Like
+// ResetDynamicCache, it is safe to call while tasks are running.
+func (e *Executor) InvalidateVar(cmd string) {
+	e.Compiler.InvalidateDynamicVar(cmd)
+}
@@ -60,6 +60,11 @@ func As(err error, target any) bool {
 	return errors.As(err, target)
 }
 
+// Join wraps the standard errors.Join function so that we don't need to alias that package.
+func Join(errs ...error) error {
+	return errors.Join(errs...)
+}
+
 // Unwrap wraps the standard errors.Unwrap function so that we don't need to alias that package.
 func Unwrap(err error) error {
 	return errors.Unwrap(err)
@@ -3,6 +3,7 @@ package errors
 import (
 	"fmt"
 	"strings"
+	"time"
 
 	"mvdan.cc/sh/v3/interp"
 )
@@ -184,3 +185,98 @@ func (err *TaskNotAllowedVars) Error() string {
 func (err *TaskNotAllowedVars) Code() int {
 	return CodeTaskNotAllowedVars
 }
+
+// DynamicVarError is returned when the command of a dynamic variable fails.
+type DynamicVarError struct {
+	Command string
+	Err     error
+	// Timeout is, when the command was stopped for exceeding it, the timeout
+	// of the variable.
+	Timeout time.Duration
+	stderr  string
+}
+
+// NewDynamicVarError returns a DynamicVarError for the given command. stderr
+// is a snapshot of what the command wrote to its standard error.
+func NewDynamicVarError(command string, err error, stderr string) *DynamicVarError {
+	return &DynamicVarError{
+		Command: command,
+		Err:     err,
+		stderr:  stderr,
+	}
+}
+
+func (err *DynamicVarError) Error() string {
+	if err.Timeout > 0 {
+		return fmt.Sprintf(`task: Command "%s" timed out after %s: %v`, err.Command, err.Timeout, err.Err)
+	}
+	if code := err.ExitCode(); code != -1 {
+		return fmt.Sprintf(`task: Command "%s" failed with exit code %d`, err.Command, code)
+	}
+	return fmt.Sprintf(`task: Command "%s" failed: %v`, err.Command, err.Err)
+}
+
+func (err *DynamicVarError) Unwrap() error {
+	return err.Err
+}
+
+// ExitCode returns the exit code of the command, or -1 if the command did not
+// exit (e.g. its shell could not be found).
+func (err *DynamicVarError) ExitCode() int {
+	if c, ok := interp.IsExitStatus(err.Err); ok {
+		return int(c)
+	}
+	return -1
+}
+
+// Stderr returns what the command wrote to its standard error. When the
+// variable captures its stderr, it is the whole output of the command instead.
+func (err *DynamicVarError) Stderr() string {
+	return err.stderr
+}
+
+// TemplateError is returned when a template can't be parsed or executed.
+type TemplateError struct {
+	// Var is the name of the variable whose template failed, if any.
+	Var string
+	// VarSource describes where Var is declared (e.g. `task "build"`), if
+	// known.
+	VarSource string
+	// Template is the source of the template that failed.
+	Template string
+	// Line is the line of the template where the error happened, starting at
+	// 1, or 0 if unknown.
+	Line int
+	// Column is the column of the template where the error happened, starting
+	// at 1, or 0 if unknown.
+	Column int
+	// Message describes the error, without its location.
+	Message string
+	Err     error
+}
+
+func (err *TemplateError) Error() string {
+	var b strings.Builder
+	switch {
+	case err.Var != "" && err.VarSource != "":
+		fmt.Fprintf(&b, `task: Failed to render the template of variable "%s" of %s: %s`, err.Var, err.VarSource, err.Message)
+	case err.Var != "":
+		fmt.Fprintf(&b, `task: Failed to render the template of variable "%s": %s`, err.Var, err.Message)
+	default:
+		fmt.Fprintf(&b, "task: Failed to render template: %s", err.Message)
+	}
+	lines := strings.Split(err.Template, "\n")
+	if err.Line < 1 || err.Line > len(lines) {
+		return b.String()
+	}
+	prefix := fmt.Sprintf("  %d | ", err.Line)
+	fmt.Fprintf(&b, "\n%s%s", prefix, lines[err.Line-1])
+	if err.Column > 0 {
+		fmt.Fprintf(&b, "\n%*s| %*s^", len(prefix)-2, "", err.Column-1, "")
+	}
+	return b.String()
+}
+
+func (err *TemplateError) Unwrap() error {
+	return err.Err
+}
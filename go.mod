@@ -3,6 +3,7 @@ module github.com/go-task/task/v3
 go 1.22.0
 
 require (
+	github.com/BurntSushi/toml v1.4.0
 	github.com/Ladicle/tabwriter v1.0.0
 	github.com/Masterminds/semver/v3 v3.3.0
 	github.com/alecthomas/chroma/v2 v2.14.0
@@ -2,177 +2,828 @@ package compiler
 
 import (
 	"bytes"
+	"cmp"
 	"context"
+	"encoding/base64"
+	"encoding/hex"
 	"fmt"
+	"io"
 	"maps"
 	"os"
 	"path/filepath"
+	"reflect"
+	"regexp"
+	"runtime"
+	"slices"
+	"strconv"
 	"strings"
 	"sync"
+	"time"
 
+	"github.com/joho/godotenv"
+	"github.com/zeebo/xxh3"
+	"mvdan.cc/sh/v3/syntax"
+
+	"github.com/go-task/task/v3/errors"
+	"github.com/go-task/task/v3/internal/env"
 	"github.com/go-task/task/v3/internal/execext"
 	"github.com/go-task/task/v3/internal/filepathext"
+	"github.com/go-task/task/v3/internal/fingerprint"
 	"github.com/go-task/task/v3/internal/logger"
 	"github.com/go-task/task/v3/internal/templater"
 	"github.com/go-task/task/v3/internal/version"
 	"github.com/go-task/task/v3/taskfile/ast"
+	"github.com/go-task/template"
 )
 
 type Compiler struct {
 	Dir            string
 	Entrypoint     string
 	UserWorkingDir string
+	RunTime        time.Time
 
 	TaskfileEnv  *ast.Vars
 	TaskfileVars *ast.Vars
+	Delims       ast.Delims
+	Funcs        template.FuncMap
+	CLIArgs      []string
+	// EnvAllow restricts the environment variables available as variables to
+	// those matching one of these names, or prefixes followed by "*". All of
+	// them are available when it is empty.
+	EnvAllow []string
+	// EnvDeny are names or prefixes of environment variables that are not
+	// available as variables, in addition to DefaultEnvDeny.
+	EnvDeny []string
+	// EnvNamespace is, when set, the name of a map variable holding the
+	// environment variables, which are still available as variables too.
+	EnvNamespace string
 
 	Logger *logger.Logger
 
-	dynamicCache   map[string]string
+	// DisableDynamicCache prevents the results of dynamic variables from being
+	// cached, so their command runs every time they are resolved.
+	DisableDynamicCache bool
+	// DynamicCacheTTL is the duration after which cached results of dynamic
+	// variables expire, unless overridden by the variable. Zero means never.
+	DynamicCacheTTL time.Duration
+	// DynamicVarProgressInterval is how often, in verbose mode, a message
+	// tells that the command of a dynamic variable is still running. Zero
+	// means DefaultDynamicVarProgressInterval. Negative disables the messages.
+	DynamicVarProgressInterval time.Duration
+	// ParallelDynamicVars makes consecutive dynamic variables that don't
+	// reference each other resolve concurrently.
+	ParallelDynamicVars bool
+	// DryRunVars makes dynamic variables resolve to DryRunPlaceholder instead
+	// of running their command, so templates can be checked without side
+	// effects.
+	DryRunVars bool
+	// StrictTemplates makes templates referencing a variable that is not set
+	// fail instead of rendering an empty string.
+	StrictTemplates bool
+	// VarTransform, if set, is called with each variable declared in the
+	// Taskfile once its value is resolved, and the variable it returns is used
+	// instead. Returning an error stops the resolution of the variables.
+	VarTransform func(name string, v ast.Var) (ast.Var, error)
+	// DynamicVarPath are directories added, in order, before the directories
+	// of PATH when running the commands of dynamic variables. Relative paths
+	// are resolved from Dir.
+	DynamicVarPath []string
+	// OnVarResolved is called, when set, after the command of a dynamic
+	// variable ran successfully. It is not called for cached results.
+	OnVarResolved func(name, cmd, value string, dur time.Duration)
+	// OnVarCacheHit is called, when set, when the cached result of a dynamic
+	// variable is used instead of running its command.
+	OnVarCacheHit func(name, cmd, value string)
+
+	dynamicCache   map[string]dynamicCacheEntry
 	muDynamicCache sync.Mutex
+
+	overrideVars   *ast.Vars
+	muOverrideVars sync.RWMutex
+}
+
+// SetVar sets a variable overriding all the other variables, including the
+// variables of calls and tasks.
+func (c *Compiler) SetVar(name string, v ast.Var) {
+	c.muOverrideVars.Lock()
+	defer c.muOverrideVars.Unlock()
+	if c.overrideVars == nil {
+		c.overrideVars = &ast.Vars{}
+	}
+	c.overrideVars.Set(name, v)
+}
+
+// SetVars sets variables overriding all the other variables, like SetVar.
+func (c *Compiler) SetVars(vars *ast.Vars) {
+	c.muOverrideVars.Lock()
+	defer c.muOverrideVars.Unlock()
+	if c.overrideVars == nil {
+		c.overrideVars = &ast.Vars{}
+	}
+	c.overrideVars.Merge(vars, nil)
+}
+
+// getOverrideVars returns a copy of the variables set by SetVar and SetVars,
+// which can be used while they are being changed.
+func (c *Compiler) getOverrideVars() *ast.Vars {
+	c.muOverrideVars.RLock()
+	defer c.muOverrideVars.RUnlock()
+	return c.overrideVars.DeepCopy()
+}
+
+type funcsKey struct{}
+
+// WithFuncs returns a copy of ctx making the variables resolved with it be
+// templated with funcs instead of Funcs.
+func WithFuncs(ctx context.Context, funcs template.FuncMap) context.Context {
+	return context.WithValue(ctx, funcsKey{}, funcs)
+}
+
+// funcs returns the template functions set in ctx by WithFuncs, or Funcs.
+func (c *Compiler) funcs(ctx context.Context) template.FuncMap {
+	if funcs, ok := ctx.Value(funcsKey{}).(template.FuncMap); ok {
+		return funcs
+	}
+	return c.Funcs
 }
 
 func (c *Compiler) GetTaskfileVariables() (*ast.Vars, error) {
-	return c.getVariables(nil, nil, true)
+	return c.getVariables(context.Background(), nil, nil, true)
 }
 
 func (c *Compiler) GetVariables(t *ast.Task, call *ast.Call) (*ast.Vars, error) {
-	return c.getVariables(t, call, true)
+	return c.getVariables(context.Background(), t, call, true)
+}
+
+// GetVariablesContext is like GetVariables, but stops resolving variables
+// and returns the error of ctx as soon as it is done, including while the
+// command of a dynamic variable is running.
+func (c *Compiler) GetVariablesContext(ctx context.Context, t *ast.Task, call *ast.Call) (*ast.Vars, error) {
+	return c.getVariables(ctx, t, call, true)
 }
 
 func (c *Compiler) FastGetVariables(t *ast.Task, call *ast.Call) (*ast.Vars, error) {
-	return c.getVariables(t, call, false)
+	return c.getVariables(context.Background(), t, call, false)
 }
 
-func (c *Compiler) getVariables(t *ast.Task, call *ast.Call, evaluateShVars bool) (*ast.Vars, error) {
-	result := GetEnviron()
+// getVariables resolves the variables of a task, merging all the layers of
+// variables. Each resolved variable records the layer it comes from in its
+// Source field.
+func (c *Compiler) getVariables(ctx context.Context, t *ast.Task, call *ast.Call, evaluateShVars bool) (*ast.Vars, error) {
+	deny := slices.Concat(DefaultEnvDeny, c.EnvDeny)
+	result := filterEnvVars(GetEnviron(), c.EnvAllow, deny, runtime.GOOS)
+	if c.EnvNamespace != "" {
+		namespace := make(map[string]any, result.Len())
+		_ = result.Range(func(k string, v ast.Var) error {
+			namespace[k] = v.Value
+			return nil
+		})
+		result.Set(c.EnvNamespace, ast.Var{Value: namespace, Source: ast.VarSourceEnviron})
+	}
 	specialVars, err := c.getSpecialVars(t, call)
 	if err != nil {
 		return nil, err
 	}
-	for k, v := range specialVars {
-		result.Set(k, ast.Var{Value: v})
+	// Special variables are set in a stable order, so resolved variables are
+	// the same from one run to another
+	names := make([]string, 0, len(specialVars))
+	for k := range specialVars {
+		names = append(names, k)
 	}
+	slices.Sort(names)
+	for _, k := range names {
+		result.Set(k, ast.Var{Value: specialVars[k], Source: ast.VarSourceSpecial})
+	}
+	result.Set("ARGS", ast.Var{Value: c.callArgs(call), Source: ast.VarSourceSpecial})
 
-	getRangeFunc := func(dir string) func(k string, v ast.Var) error {
+	getRangeFunc := func(dir string, layer ast.VarSource) func(k string, v ast.Var) error {
 		return func(k string, v ast.Var) error {
-			cache := &templater.Cache{Vars: result}
+			if err := ctx.Err(); err != nil {
+				return err
+			}
+			// Variables can keep the source they were read from, like the
+			// variables of Taskvars files merged into the Taskfile ones.
+			source := cmp.Or(v.Source, layer)
+			if evaluateShVars {
+				c.logOverride(result, k, source)
+				if err := c.resolveLazyRefs(ctx, result, v); err != nil {
+					return err
+				}
+			}
+			// A conditional variable is skipped, as if it wasn't declared,
+			// unless the variable of its condition is set
+			if v.When != "" && !isSet(result, v.When) {
+				return nil
+			}
+			cache := &templater.Cache{Vars: result, Delims: cmp.Or(v.Delims, c.Delims), Funcs: c.funcs(ctx), Strict: c.StrictTemplates}
 			// Replace values
 			newVar := templater.ReplaceVar(v, cache)
 			// If the variable should not be evaluated, but is nil, set it to an empty string
 			// This stops empty interface errors when using the templater to replace values later
 			if !evaluateShVars && newVar.Value == nil {
-				result.Set(k, ast.Var{Value: ""})
+				result.Set(k, ast.Var{Value: "", Secret: newVar.Secret, Source: source, Dynamic: newVar.Sh != nil})
 				return nil
 			}
 			// If the variable should not be evaluated and it is set, we can set it and return
 			if !evaluateShVars {
-				result.Set(k, ast.Var{Value: newVar.Value})
-				return nil
+				c.addSecret(newVar, newVar.Value)
+				return c.setVar(result, k, newVar.Merge, ast.Var{Value: newVar.Value, Secret: newVar.Secret, Source: source})
 			}
 			// Now we can check for errors since we've handled all the cases when we don't want to evaluate
 			if err := cache.Err(); err != nil {
+				templater.SetErrVar(err, k)
 				return err
 			}
 			// If the variable is already set, we can set it and return
 			if newVar.Value != nil {
-				result.Set(k, ast.Var{Value: newVar.Value})
+				c.addSecret(newVar, newVar.Value)
+				return c.setVar(result, k, newVar.Merge, ast.Var{Value: newVar.Value, Secret: newVar.Secret, Source: source})
+			}
+			// If the variable is read from a file, read it now
+			if newVar.File != "" {
+				content, err := c.readFileVar(k, newVar)
+				if err != nil {
+					return err
+				}
+				c.addSecret(newVar, content)
+				return c.setVar(result, k, newVar.Merge, ast.Var{Value: content, Secret: newVar.Secret, Source: source})
+			}
+			// If the variable is a glob, expand it now
+			if newVar.Glob != "" {
+				files, err := c.globVar(k, newVar)
+				if err != nil {
+					return err
+				}
+				return c.setVar(result, k, newVar.Merge, ast.Var{Value: files, Secret: newVar.Secret, Source: source})
+			}
+			// If the variable is lazy, its command runs once it is referenced.
+			// See ResolveLazyVars.
+			if newVar.Lazy {
+				newVar.Dir = filepathext.SmartJoin(dir, newVar.Dir)
+				newVar.Source = source
+				newVar.Dynamic = true
+				result.Set(k, newVar)
 				return nil
 			}
 			// If the variable is dynamic, we need to resolve it first
-			static, err := c.HandleDynamicVar(newVar, dir)
+			static, err := c.HandleNamedDynamicVar(ctx, k, newVar, dir, env.FromVars(result))
 			if err != nil {
 				return err
 			}
-			result.Set(k, ast.Var{Value: static})
-			return nil
+			if newVar.Export {
+				return c.setExportedVars(result, k, newVar, static, source)
+			}
+			return c.setVar(result, k, newVar.Merge, ast.Var{Value: static, Secret: newVar.Secret, Source: source, Dynamic: true})
 		}
 	}
-	rangeFunc := getRangeFunc(c.Dir)
 
-	var taskRangeFunc func(k string, v ast.Var) error
+	// Order all the variables before resolving any of them, so that circular
+	// references are reported before any dynamic variable command is run.
+	overrideVars := c.getOverrideVars()
+	layers := []*ast.Vars{c.TaskfileEnv, c.TaskfileVars}
 	if t != nil {
-		// NOTE(@andreynering): We're manually joining these paths here because
-		// this is the raw task, not the compiled one.
-		cache := &templater.Cache{Vars: result}
-		dir := templater.Replace(t.Dir, cache)
-		if err := cache.Err(); err != nil {
+		layers = append(layers, t.ParentTaskfileVars, t.IncludeVars, t.IncludedTaskfileVars)
+		if call != nil {
+			layers = append(layers, call.Vars, t.Vars)
+		}
+	}
+	layers = append(layers, overrideVars)
+	ordered := make(map[*ast.Vars]*ast.Vars, len(layers))
+	for _, vars := range layers {
+		if ordered[vars], err = orderVars(vars, c.Delims); err != nil {
 			return nil, err
 		}
-		dir = filepathext.SmartJoin(c.Dir, dir)
-		taskRangeFunc = getRangeFunc(dir)
 	}
 
-	if err := c.TaskfileEnv.Range(rangeFunc); err != nil {
+	// rangeVars resolves the given variables in order, after the variables
+	// they reference. When enabled, independent dynamic variables are resolved
+	// concurrently.
+	rangeVars := func(vars *ast.Vars, dir string, layer ast.VarSource) error {
+		vars = ordered[vars]
+		rangeFunc := getRangeFunc(dir, layer)
+		if !c.ParallelDynamicVars || !evaluateShVars {
+			return vars.Range(rangeFunc)
+		}
+		for _, batch := range batchVars(vars) {
+			if len(batch) == 1 {
+				if err := rangeFunc(batch[0].name, batch[0].v); err != nil {
+					return err
+				}
+				continue
+			}
+			for _, nv := range batch {
+				if err := c.resolveLazyRefs(ctx, result, nv.v); err != nil {
+					return err
+				}
+			}
+			if err := c.resolveDynamicVarsInParallel(ctx, batch, dir, layer, result); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+
+	if err := rangeVars(c.TaskfileEnv, c.Dir, ast.VarSourceTaskfileEnv); err != nil {
 		return nil, err
 	}
-	if err := c.TaskfileVars.Range(rangeFunc); err != nil {
+	if err := rangeVars(c.TaskfileVars, c.Dir, ast.VarSourceTaskfile); err != nil {
 		return nil, err
 	}
+
+	var taskDir string
 	if t != nil {
-		if err := t.IncludeVars.Range(rangeFunc); err != nil {
+		if err := rangeVars(t.ParentTaskfileVars, c.Dir, ast.VarSourceParentTaskfile); err != nil {
+			return nil, err
+		}
+		if err := rangeVars(t.IncludeVars, c.Dir, ast.VarSourceInclude); err != nil {
 			return nil, err
 		}
-		if err := t.IncludedTaskfileVars.Range(taskRangeFunc); err != nil {
+
+		// NOTE(@andreynering): We're manually joining these paths here because
+		// this is the raw task, not the compiled one.
+		cache := &templater.Cache{Vars: result, Delims: cmp.Or(t.Delims, c.Delims), Funcs: c.funcs(ctx), Strict: c.StrictTemplates}
+		dir := templater.Replace(t.Dir, cache)
+		if err := cache.Err(); err != nil {
+			return nil, err
+		}
+		taskDir = filepathext.SmartJoin(c.Dir, dir)
+
+		// The directory of the task is only known once the variables it may
+		// reference are resolved, so TASK_DIR is set here. Variables of the
+		// same name declared in the Taskfile take precedence.
+		if !result.Exists("TASK_DIR") || result.Get("TASK_DIR").Source == ast.VarSourceEnviron {
+			result.Set("TASK_DIR", ast.Var{Value: taskDir, Source: ast.VarSourceSpecial})
+		}
+
+		if err := rangeVars(t.IncludedTaskfileVars, taskDir, ast.VarSourceIncludedTaskfile); err != nil {
 			return nil, err
 		}
 	}
 
-	if t == nil || call == nil {
-		return result, nil
+	if t != nil && call != nil {
+		if err := rangeVars(call.Vars, c.Dir, ast.VarSourceCall); err != nil {
+			return nil, err
+		}
+		if err := rangeVars(t.Vars, taskDir, ast.VarSourceTask); err != nil {
+			return nil, err
+		}
 	}
 
-	if err := call.Vars.Range(rangeFunc); err != nil {
+	if err := rangeVars(overrideVars, c.Dir, ast.VarSourceOverride); err != nil {
 		return nil, err
 	}
-	if err := t.Vars.Range(taskRangeFunc); err != nil {
-		return nil, err
+
+	return result, nil
+}
+
+// logOverride logs, in verbose mode, when a variable overrides a variable of
+// the same name coming from another source.
+func (c *Compiler) logOverride(result *ast.Vars, name string, source ast.VarSource) {
+	if !result.Exists(name) {
+		return
+	}
+	if prev := result.Get(name).Source; prev != source {
+		c.Logger.VerboseErrf(logger.Magenta, "task: variable %q from %s overridden by %s\n", name, prev, source)
+	}
+}
+
+// setVar sets the resolved variable v into result, after merging it with the
+// previous variable of the same name and applying VarTransform.
+func (c *Compiler) setVar(result *ast.Vars, name string, merge string, v ast.Var) error {
+	v = mergeVar(result, name, merge, v)
+	if c.VarTransform != nil {
+		var err error
+		if v, err = c.VarTransform(name, v); err != nil {
+			return fmt.Errorf(`task: Failed to transform variable "%s": %w`, name, err)
+		}
+		c.addSecret(v, v.Value)
+	}
+	result.Set(name, v)
+	return nil
+}
+
+// exportedNameRegex matches the names of the variables a dynamic variable can
+// export, which are the valid names of environment variables.
+var exportedNameRegex = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_]*$`)
+
+// setExportedVars sets the variables declared by the KEY=VALUE lines of the
+// output of the dynamic variable v, in the order of their names, like if they
+// were declared in place of v. Lines are read like the lines of a dotenv file,
+// so values can be quoted and the last value of a duplicate key wins. The
+// variable itself is set to a map of the exported variables.
+func (c *Compiler) setExportedVars(result *ast.Vars, name string, v ast.Var, output any, source ast.VarSource) error {
+	exported, err := godotenv.Unmarshal(fmt.Sprint(output))
+	if err != nil {
+		return fmt.Errorf(`task: Failed to read the variables exported by variable "%s": %w`, name, err)
+	}
+	keys := make([]string, 0, len(exported))
+	values := make(map[string]any, len(exported))
+	for k, value := range exported {
+		keys = append(keys, k)
+		values[k] = value
+	}
+	slices.Sort(keys)
+	for _, k := range keys {
+		if !exportedNameRegex.MatchString(k) {
+			return fmt.Errorf(`task: Failed to read the variables exported by variable "%s": %q is not a valid variable name. Lines must look like KEY=VALUE`, name, k)
+		}
+		c.addSecret(v, exported[k])
+		if err := c.setVar(result, k, "", ast.Var{Value: exported[k], Secret: v.Secret, Source: source, Dynamic: true}); err != nil {
+			return err
+		}
+	}
+	return c.setVar(result, name, "", ast.Var{Value: values, Secret: v.Secret, Source: source, Dynamic: true})
+}
+
+// isSet reports whether the variable name of vars is set to a value that is
+// not empty, i.e. not an empty string, list or map.
+func isSet(vars *ast.Vars, name string) bool {
+	if !vars.Exists(name) {
+		return false
+	}
+	value := vars.Get(name).Value
+	if value == nil {
+		return false
+	}
+	switch rv := reflect.ValueOf(value); rv.Kind() {
+	case reflect.String, reflect.Slice, reflect.Map:
+		return rv.Len() > 0
+	default:
+		return true
+	}
+}
+
+// mergeVar returns v, with the value of the variable of the same name in result
+// added before its own when merge is "append" and both values are lists.
+// Otherwise, v replaces the previous variable.
+func mergeVar(result *ast.Vars, name string, merge string, v ast.Var) ast.Var {
+	if merge != ast.MergeAppend || !result.Exists(name) {
+		return v
+	}
+	prev, ok := listItems(result.Get(name).Value)
+	if !ok {
+		return v
 	}
+	items, ok := listItems(v.Value)
+	if !ok {
+		return v
+	}
+	v.Value = slices.Concat(prev, items)
+	return v
+}
+
+// listItems returns the items of value if it is a list, like a list variable
+// or the value of a glob or split dynamic variable.
+func listItems(value any) ([]any, bool) {
+	switch value := value.(type) {
+	case []any:
+		return value, true
+	case []string:
+		items := make([]any, len(value))
+		for i, item := range value {
+			items[i] = item
+		}
+		return items, true
+	default:
+		return nil, false
+	}
+}
 
+// readFileVar returns the contents of the file of a variable, trimmed like
+// the output of a dynamic variable. Relative paths are resolved from the
+// directory of the root Taskfile.
+func (c *Compiler) readFileVar(name string, v ast.Var) (string, error) {
+	b, err := os.ReadFile(filepathext.SmartJoin(c.Dir, v.File))
+	if err != nil {
+		return "", fmt.Errorf(`task: Failed to read the file of variable "%s": %w`, name, err)
+	}
+	return trimOutput(string(b), v.Trim), nil
+}
+
+// globVar returns the files matching the glob of a variable, sorted and
+// relative to the directory of the root Taskfile. The glob can use "**" to
+// match any number of directories, and matches nothing rather than failing
+// when no file exists.
+func (c *Compiler) globVar(name string, v ast.Var) ([]any, error) {
+	files, err := fingerprint.Glob(c.Dir, v.Glob)
+	if err != nil && !errors.Is(err, os.ErrNotExist) {
+		return nil, fmt.Errorf(`task: Failed to expand the glob of variable "%s": %w`, name, err)
+	}
+	slices.Sort(files)
+	result := make([]any, len(files))
+	for i, file := range files {
+		if rel, err := filepath.Rel(c.Dir, file); err == nil {
+			file = rel
+		}
+		result[i] = file
+	}
 	return result, nil
 }
 
-func (c *Compiler) HandleDynamicVar(v ast.Var, dir string) (string, error) {
-	c.muDynamicCache.Lock()
-	defer c.muDynamicCache.Unlock()
+// DryRunPlaceholder is the value of dynamic variables when DryRunVars is set.
+// Variables with "split: true" are a list with it as the only item, and
+// exported variables export no variables.
+const DryRunPlaceholder = "<dynamic>"
+
+// dryRunValue returns the value of v when DryRunVars is set, with the same
+// shape as the value of its command would have, so templates ranging over a
+// split variable still render.
+func dryRunValue(v ast.Var) any {
+	switch {
+	case v.Export:
+		return ""
+	case v.Split:
+		return []string{DryRunPlaceholder}
+	default:
+		return DryRunPlaceholder
+	}
+}
 
+// HandleDynamicVar runs the command of a dynamic variable and returns its
+// output. The command is run with the given environment, or the current
+// process environment when it is empty.
+func (c *Compiler) HandleDynamicVar(v ast.Var, dir string, environ []string) (any, error) {
+	return c.HandleDynamicVarContext(context.Background(), v, dir, environ)
+}
+
+// HandleDynamicVarContext is like HandleDynamicVar, but the command is killed
+// and the error of ctx is returned when it is done.
+func (c *Compiler) HandleDynamicVarContext(ctx context.Context, v ast.Var, dir string, environ []string) (any, error) {
+	return c.HandleNamedDynamicVar(ctx, "", v, dir, environ)
+}
+
+// HandleNamedDynamicVar is like HandleDynamicVarContext, but also takes the
+// name of the variable, which is passed to OnVarResolved and OnVarCacheHit.
+func (c *Compiler) HandleNamedDynamicVar(ctx context.Context, name string, v ast.Var, dir string, environ []string) (any, error) {
 	// If the variable is not dynamic or it is empty, return an empty string
 	if v.Sh == nil || *v.Sh == "" {
 		return "", nil
 	}
 
-	if c.dynamicCache == nil {
-		c.dynamicCache = make(map[string]string, 30)
-	}
-	if result, ok := c.dynamicCache[*v.Sh]; ok {
-		return result, nil
+	if c.DryRunVars {
+		c.Logger.VerboseErrf(logger.Magenta, "task: dynamic variable: %q not run\n", *v.Sh)
+		return dryRunValue(v), nil
 	}
 
-	// NOTE(@andreynering): If a var have a specific dir, use this instead
+	// NOTE(@andreynering): If a var have a specific dir, use this instead.
+	// Relative directories are resolved from the one the command would
+	// otherwise run in.
 	if v.Dir != "" {
-		dir = v.Dir
+		dir = filepathext.SmartJoin(dir, v.Dir)
 	}
 
-	var stdout bytes.Buffer
+	key := dynamicCacheKey(v, dir, environ)
+	entry, ok := c.getDynamicCacheEntry(key, cmp.Or(v.TTL, c.DynamicCacheTTL), v.Persist)
+	if !ok {
+		start := time.Now()
+		result, err := c.runDynamicVarWithRetries(ctx, v, dir, environ)
+		if err != nil {
+			// An optional variable is empty when its command fails, but not
+			// when the resolution of the variables is canceled. The empty
+			// value isn't cached, so the command is run again next time.
+			if !v.Optional || ctx.Err() != nil {
+				return "", err
+			}
+			c.Logger.VerboseErrf(logger.Yellow, "%v, using an empty value\n", err)
+			if v.Split {
+				return []string{}, nil
+			}
+			return "", nil
+		}
+		dur := time.Since(start)
+		entry = dynamicCacheEntry{Value: result, Command: *v.Sh, CreatedAt: time.Now(), persist: v.Persist}
+		c.setDynamicCacheEntry(key, entry)
+		c.addSecret(v, result)
+		c.Logger.VerboseErrf(logger.Magenta, "task: dynamic variable: %q result: %q\n", *v.Sh, result)
+		if c.OnVarResolved != nil {
+			c.OnVarResolved(name, c.Logger.Redact(*v.Sh), c.Logger.Redact(result), dur)
+		}
+	} else if c.OnVarCacheHit != nil {
+		c.OnVarCacheHit(name, c.Logger.Redact(*v.Sh), c.Logger.Redact(entry.Value))
+	}
+	result := entry.Value
+	c.addSecret(v, result)
+
+	if !v.Split {
+		return c.convertOutput(*v.Sh, result, v.Type)
+	}
+	lines := templater.SplitLines(result)
+	c.addSecret(v, lines)
+	if v.Type == "" || v.Type == ast.TypeString {
+		return lines, nil
+	}
+	items := make([]any, len(lines))
+	for i, line := range lines {
+		item, err := c.convertOutput(*v.Sh, line, v.Type)
+		if err != nil {
+			return "", err
+		}
+		items[i] = item
+	}
+	return items, nil
+}
+
+// convertOutput converts the output of a dynamic variable to the given type.
+func (c *Compiler) convertOutput(command, s string, typ string) (any, error) {
+	switch typ {
+	case ast.TypeBool:
+		b, err := strconv.ParseBool(strings.TrimSpace(s))
+		if err != nil {
+			return "", fmt.Errorf(`task: Command "%s" output %q is not a valid bool`, c.Logger.Redact(command), c.Logger.Redact(s))
+		}
+		return b, nil
+	case ast.TypeInt:
+		i, err := strconv.Atoi(strings.TrimSpace(s))
+		if err != nil {
+			return "", fmt.Errorf(`task: Command "%s" output %q is not a valid int`, c.Logger.Redact(command), c.Logger.Redact(s))
+		}
+		return i, nil
+	default:
+		return s, nil
+	}
+}
+
+// runDynamicVarWithRetries runs the command of a dynamic variable like
+// runDynamicVar, running it again up to v.Retries times while it exits with a
+// non-zero code. Only the error of the last attempt is returned.
+func (c *Compiler) runDynamicVarWithRetries(ctx context.Context, v ast.Var, dir string, environ []string) (string, error) {
+	for attempt := 1; ; attempt++ {
+		result, err := c.runDynamicVar(ctx, v, dir, environ)
+		var dynamicVarErr *errors.DynamicVarError
+		if err == nil || attempt > v.Retries || !errors.As(err, &dynamicVarErr) || dynamicVarErr.ExitCode() == -1 {
+			return result, err
+		}
+		c.Logger.VerboseErrf(logger.Yellow, "task: dynamic variable: %q failed, retrying (%d/%d)\n", c.Logger.Redact(*v.Sh), attempt, v.Retries)
+		select {
+		case <-ctx.Done():
+			return "", ctx.Err()
+		case <-time.After(v.RetryDelay):
+		}
+	}
+}
+
+// runDynamicVar runs the command of a dynamic variable and returns its
+// trimmed output. When the stderr of the variable is captured, the output
+// contains what the command wrote to both stdout and stderr.
+func (c *Compiler) runDynamicVar(parent context.Context, v ast.Var, dir string, environ []string) (string, error) {
+	var stdout, stderr bytes.Buffer
 	opts := &execext.RunCommandOptions{
 		Command: *v.Sh,
+		Shell:   v.Shell,
 		Dir:     dir,
+		Env:     prependPath(environ, c.dynamicVarPath(), runtime.GOOS),
 		Stdout:  &stdout,
-		Stderr:  c.Logger.Stderr,
+		Stderr:  io.MultiWriter(c.Logger.Stderr, &stderr),
+	}
+	if v.Stdin != "" {
+		opts.Stdin = strings.NewReader(v.Stdin)
 	}
-	if err := execext.RunCommand(context.Background(), opts); err != nil {
-		return "", fmt.Errorf(`task: Command "%s" failed: %s`, opts.Command, err)
+	if v.Stderr == ast.StderrCapture {
+		// Using the same writer makes sure it is never written to concurrently
+		opts.Stderr = &stdout
 	}
+	ctx := parent
+	if v.Timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, v.Timeout)
+		defer cancel()
+	}
+	stopProgress := c.reportProgress(opts.Command)
+	err := execext.RunCommand(ctx, opts)
+	stopProgress()
+	if err != nil {
+		if err := parent.Err(); err != nil {
+			return "", err
+		}
+		output := stderr.String()
+		if v.Stderr == ast.StderrCapture {
+			output = stdout.String()
+		}
+		if errors.Is(ctx.Err(), context.DeadlineExceeded) {
+			timeoutErr := errors.NewDynamicVarError(c.Logger.Redact(opts.Command), ctx.Err(), c.Logger.Redact(output))
+			timeoutErr.Timeout = v.Timeout
+			return "", timeoutErr
+		}
+		return "", errors.NewDynamicVarError(c.Logger.Redact(opts.Command), err, c.Logger.Redact(output))
+	}
+	if v.NonEmpty && strings.TrimSpace(stdout.String()) == "" {
+		return "", fmt.Errorf(`task: Command "%s" succeeded but its output is empty`, c.Logger.Redact(opts.Command))
+	}
+	if v.Encode != "" && v.Encode != ast.EncodeNone {
+		return encodeOutput(stdout.Bytes(), v.Encode), nil
+	}
+	return trimOutput(stdout.String(), v.Trim), nil
+}
 
-	// Trim a single trailing newline from the result to make most command
-	// output easier to use in shell commands.
-	result := strings.TrimSuffix(stdout.String(), "\r\n")
-	result = strings.TrimSuffix(result, "\n")
+// DefaultDynamicVarProgressInterval is how often a message tells that the
+// command of a dynamic variable is still running, unless configured otherwise.
+const DefaultDynamicVarProgressInterval = 10 * time.Second
 
-	c.dynamicCache[*v.Sh] = result
-	c.Logger.VerboseErrf(logger.Magenta, "task: dynamic variable: %q result: %q\n", v.Sh, result)
+// reportProgress prints, in verbose mode, that command is still running every
+// DynamicVarProgressInterval, until the returned function is called. It
+// returns once no message can be printed anymore.
+func (c *Compiler) reportProgress(command string) (stop func()) {
+	interval := cmp.Or(c.DynamicVarProgressInterval, DefaultDynamicVarProgressInterval)
+	if c.Logger == nil || !c.Logger.Verbose || interval < 0 {
+		return func() {}
+	}
+	start := time.Now()
+	ticker := time.NewTicker(interval)
+	done := make(chan struct{})
+	var wg sync.WaitGroup
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		for {
+			select {
+			case <-done:
+				return
+			case now := <-ticker.C:
+				c.Logger.VerboseErrf(logger.Yellow, "task: dynamic variable: still running: %q (%ds)\n", c.Logger.Redact(command), int(now.Sub(start).Seconds()))
+			}
+		}
+	}()
+	return func() {
+		ticker.Stop()
+		close(done)
+		wg.Wait()
+	}
+}
 
-	return result, nil
+// encodeOutput encodes the raw, untrimmed output of a dynamic variable.
+func encodeOutput(b []byte, encoding string) string {
+	switch encoding {
+	case ast.EncodeBase64:
+		return base64.StdEncoding.EncodeToString(b)
+	case ast.EncodeHex:
+		return hex.EncodeToString(b)
+	default:
+		return string(b)
+	}
+}
+
+// dynamicVarPath returns the directories of DynamicVarPath, with relative
+// paths resolved from Dir.
+func (c *Compiler) dynamicVarPath() []string {
+	dirs := make([]string, len(c.DynamicVarPath))
+	for i, dir := range c.DynamicVarPath {
+		dirs[i] = filepathext.SmartJoin(c.Dir, dir)
+	}
+	return dirs
+}
+
+// dynamicCacheKey returns the key used to store the result of a dynamic
+// variable in the cache. Anything that can change the cached value (options,
+// the directory and the environment variables the command references) must be
+// part of the key, so the same command can be cached once per combination.
+//
+// Only the environment variables referenced as shell variables (e.g. $FOO) are
+// part of the key. Otherwise, the special variables of each task, like TASK,
+// would make a Taskfile variable run again for every task.
+//
+// Results that are persisted to disk are not keyed by the environment, which
+// is rarely identical between two runs of Task.
+func dynamicCacheKey(v ast.Var, dir string, environ []string) string {
+	h := xxh3.New()
+	_, _ = h.WriteString(dir + "\x00" + v.Trim + "\x00" + v.Shell)
+	if v.Encode != "" {
+		_, _ = h.WriteString("\x00encode\x00" + v.Encode)
+	}
+	if v.Stderr == ast.StderrCapture {
+		_, _ = h.WriteString("\x00" + v.Stderr)
+	}
+	if v.Stdin != "" {
+		_, _ = h.WriteString("\x00stdin\x00" + v.Stdin)
+	}
+	if !v.Persist {
+		refs := shellRefRegex.FindAllStringSubmatch(*v.Sh, -1)
+		names := make(map[string]bool, len(refs))
+		for _, ref := range refs {
+			names[ref[1]] = true
+		}
+		// Later entries take precedence, so their order is kept
+		for _, e := range environ {
+			if name, _, _ := strings.Cut(e, "="); names[name] {
+				_, _ = h.WriteString("\x00" + e)
+			}
+		}
+	}
+	return fmt.Sprintf("%x:%s", h.Sum64(), *v.Sh)
+}
+
+// trimOutput trims the output of a dynamic variable according to the given
+// mode. By default, a single trailing newline is trimmed to make most command
+// output easier to use in shell commands.
+func trimOutput(s string, mode string) string {
+	switch mode {
+	case ast.TrimNone:
+		return s
+	case ast.TrimSpace:
+		return strings.TrimSpace(s)
+	default:
+		s = strings.TrimSuffix(s, "\r\n")
+		return strings.TrimSuffix(s, "\n")
+	}
 }
 
 // ResetCache clear the dynamic variables cache
@@ -190,7 +841,13 @@ func (c *Compiler) getSpecialVars(t *ast.Task, call *ast.Call) (map[string]strin
 		"ROOT_DIR":         c.Dir,
 		"USER_WORKING_DIR": c.UserWorkingDir,
 		"TASK_VERSION":     version.GetVersion(),
+		"RUN_TIME":         c.RunTime.Format(time.RFC3339),
+	}
+	cliArgs, err := QuoteArgs(c.CLIArgs)
+	if err != nil {
+		return nil, err
 	}
+	allVars["CLI_ARGS"] = cliArgs
 	if t != nil {
 		maps.Copy(allVars, map[string]string{"TASK": t.Task, "TASKFILE": t.Location.Taskfile, "TASKFILE_DIR": filepath.Dir(t.Location.Taskfile)})
 	}
@@ -199,3 +856,43 @@ func (c *Compiler) getSpecialVars(t *ast.Task, call *ast.Call) (map[string]strin
 	}
 	return allVars, nil
 }
+
+// callArgs returns the positional arguments of call, which are the CLI
+// arguments unless the call gives its own.
+func (c *Compiler) callArgs(call *ast.Call) []string {
+	args := c.CLIArgs
+	if call != nil && call.Args != nil {
+		args = call.Args
+	}
+	return append([]string{}, args...)
+}
+
+// addSecret makes the logger redact value if v is a secret variable. Only
+// string values are redacted, since redacting values like "true" or "1" would
+// hide unrelated output.
+func (c *Compiler) addSecret(v ast.Var, value any) {
+	if !v.Secret {
+		return
+	}
+	switch value := value.(type) {
+	case string:
+		c.Logger.AddSecret(value)
+	case []string:
+		for _, s := range value {
+			c.Logger.AddSecret(s)
+		}
+	}
+}
+
+// QuoteArgs quotes args for the shell and joins them with spaces, the way
+// they are given to the CLI_ARGS special variable.
+func QuoteArgs(args []string) (string, error) {
+	quoted := make([]string, len(args))
+	for i, arg := range args {
+		var err error
+		if quoted[i], err = syntax.Quote(arg, syntax.LangBash); err != nil {
+			return "", err
+		}
+	}
+	return strings.Join(quoted, " "), nil
+}
@@ -0,0 +1,158 @@
+package compiler
+
+import (
+	"encoding/json"
+	"fmt"
+	"maps"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+// dynamicCacheVersion is the version of the format used to persist the
+// dynamic variables cache to disk. It should be bumped whenever a change is
+// made that older versions of Task can't read.
+const dynamicCacheVersion = 1
+
+type dynamicCacheEntry struct {
+	Value     string    `json:"value"`
+	Command   string    `json:"command,omitempty"`
+	CreatedAt time.Time `json:"created_at"`
+
+	// persist is set on the entries of dynamic variables marked with
+	// "persist" that were resolved in this run, whether by running their
+	// command or from the persisted cache. Only those are saved.
+	persist bool
+}
+
+// expired reports whether the entry is older than the given TTL. A TTL of zero
+// means that the entry never expires.
+func (entry dynamicCacheEntry) expired(ttl time.Duration) bool {
+	return ttl > 0 && time.Since(entry.CreatedAt) > ttl
+}
+
+type dynamicCacheFile struct {
+	Version int                          `json:"version"`
+	Entries map[string]dynamicCacheEntry `json:"entries"`
+}
+
+// getDynamicCacheEntry returns the cached result for the given key, unless it
+// is older than the given TTL or the cache is disabled. When persist is set,
+// the entry is marked to be saved by SaveDynamicCache. The lock is not held
+// while the command runs, so several dynamic variables can be resolved
+// concurrently.
+func (c *Compiler) getDynamicCacheEntry(key string, ttl time.Duration, persist bool) (dynamicCacheEntry, bool) {
+	c.muDynamicCache.Lock()
+	defer c.muDynamicCache.Unlock()
+
+	if c.DisableDynamicCache {
+		return dynamicCacheEntry{}, false
+	}
+	entry, ok := c.dynamicCache[key]
+	if !ok || entry.expired(ttl) {
+		return dynamicCacheEntry{}, false
+	}
+	if persist && !entry.persist {
+		entry.persist = true
+		c.dynamicCache[key] = entry
+	}
+	return entry, true
+}
+
+func (c *Compiler) setDynamicCacheEntry(key string, entry dynamicCacheEntry) {
+	c.muDynamicCache.Lock()
+	defer c.muDynamicCache.Unlock()
+
+	if c.DisableDynamicCache {
+		return
+	}
+	if c.dynamicCache == nil {
+		c.dynamicCache = make(map[string]dynamicCacheEntry, 30)
+	}
+	c.dynamicCache[key] = entry
+}
+
+// InvalidateDynamicVar removes the cached results of the dynamic variables
+// running the given command, whatever their options and the directory and
+// environment they ran with.
+func (c *Compiler) InvalidateDynamicVar(command string) {
+	c.muDynamicCache.Lock()
+	defer c.muDynamicCache.Unlock()
+
+	maps.DeleteFunc(c.dynamicCache, func(_ string, entry dynamicCacheEntry) bool {
+		return entry.Command == command
+	})
+}
+
+// LoadDynamicCache reads the persisted results of dynamic variables from the
+// given file into the cache. A missing file or one written in a newer,
+// unknown format is ignored. The results are only saved again if they are
+// used by this run.
+func (c *Compiler) LoadDynamicCache(path string) error {
+	c.muDynamicCache.Lock()
+	defer c.muDynamicCache.Unlock()
+
+	b, err := os.ReadFile(path)
+	if os.IsNotExist(err) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+
+	var f dynamicCacheFile
+	if err := json.Unmarshal(b, &f); err != nil {
+		return fmt.Errorf("task: unable to read dynamic variables cache %q: %w", path, err)
+	}
+	if f.Version > dynamicCacheVersion {
+		return nil
+	}
+
+	if c.dynamicCache == nil {
+		c.dynamicCache = make(map[string]dynamicCacheEntry, len(f.Entries))
+	}
+	for k, entry := range f.Entries {
+		if _, ok := c.dynamicCache[k]; ok {
+			continue
+		}
+		c.dynamicCache[k] = entry
+	}
+	return nil
+}
+
+// SaveDynamicCache writes the results of the dynamic variables marked to be
+// persisted that were resolved in this run to the given file, replacing the
+// results of previous runs. The file is removed if there are no such results.
+// Nothing is done when the cache is disabled.
+func (c *Compiler) SaveDynamicCache(path string) error {
+	c.muDynamicCache.Lock()
+	defer c.muDynamicCache.Unlock()
+
+	if c.DisableDynamicCache {
+		return nil
+	}
+	f := dynamicCacheFile{
+		Version: dynamicCacheVersion,
+		Entries: make(map[string]dynamicCacheEntry),
+	}
+	for k, entry := range c.dynamicCache {
+		if entry.persist {
+			f.Entries[k] = entry
+		}
+	}
+	if len(f.Entries) == 0 {
+		if err := os.Remove(path); err != nil && !os.IsNotExist(err) {
+			return err
+		}
+		return nil
+	}
+
+	b, err := json.MarshalIndent(f, "", "  ")
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		return err
+	}
+	return os.WriteFile(path, b, 0o644)
+}
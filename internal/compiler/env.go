@@ -2,6 +2,8 @@ package compiler
 
 import (
 	"os"
+	"runtime"
+	"slices"
 	"strings"
 
 	"github.com/go-task/task/v3/taskfile/ast"
@@ -10,11 +12,100 @@ import (
 // GetEnviron the all return all environment variables encapsulated on a
 // ast.Vars
 func GetEnviron() *ast.Vars {
+	return environToVars(os.Environ(), runtime.GOOS)
+}
+
+// environToVars returns the variables of environ, in order. When a name is set
+// more than once, e.g. names that only differ by case on Windows, the last value
+// wins, like it does when running commands, and the name keeps its first
+// position.
+func environToVars(environ []string, goos string) *ast.Vars {
 	m := &ast.Vars{}
-	for _, e := range os.Environ() {
-		keyVal := strings.SplitN(e, "=", 2)
-		key, val := keyVal[0], keyVal[1]
-		m.Set(key, ast.Var{Value: val})
+	for _, e := range environ {
+		key, val, _ := strings.Cut(e, "=")
+		// NOTE: Environment variable names are case-insensitive on Windows,
+		// so we normalize them to make templates like {{.PATH}} work no matter
+		// how the variable is capitalized.
+		if goos == "windows" {
+			key = strings.ToUpper(key)
+		}
+		m.Set(key, ast.Var{Value: val, Source: ast.VarSourceEnviron})
 	}
 	return m
 }
+
+// DefaultEnvDeny are the patterns of environment variables that are never
+// available as variables, in addition to the ones configured by users.
+var DefaultEnvDeny = []string{
+	"_", // Set by shells to the last argument of the previous command
+}
+
+// filterEnvVars returns the environment variables whose name matches one of
+// the allowed patterns, or any name if there are no allowed patterns, and none
+// of the denied patterns. See matchEnvName for the syntax of patterns.
+func filterEnvVars(vars *ast.Vars, allow, deny []string, goos string) *ast.Vars {
+	if len(allow) == 0 && len(deny) == 0 {
+		return vars
+	}
+	filtered := &ast.Vars{}
+	_ = vars.Range(func(k string, v ast.Var) error {
+		if len(allow) > 0 && !matchEnvName(allow, k, goos) {
+			return nil
+		}
+		if matchEnvName(deny, k, goos) {
+			return nil
+		}
+		filtered.Set(k, v)
+		return nil
+	})
+	return filtered
+}
+
+// matchEnvName reports whether the name of an environment variable matches one
+// of the patterns. A pattern is either a name, or a prefix followed by "*".
+// Patterns are uppercased on Windows, like the names of environment variables.
+func matchEnvName(patterns []string, name string, goos string) bool {
+	for _, pattern := range patterns {
+		if goos == "windows" {
+			pattern = strings.ToUpper(pattern)
+		}
+		if prefix, ok := strings.CutSuffix(pattern, "*"); ok {
+			if strings.HasPrefix(name, prefix) {
+				return true
+			}
+		} else if name == pattern {
+			return true
+		}
+	}
+	return false
+}
+
+// prependPath returns a copy of environ with dirs added, in order, before the
+// directories of its PATH variable, so they are searched first. The PATH
+// variable is matched case-insensitively on Windows. Like for
+// execext.RunCommand, an empty environ means the environment of Task.
+func prependPath(environ []string, dirs []string, goos string) []string {
+	if len(dirs) == 0 {
+		return environ
+	}
+	if len(environ) == 0 {
+		environ = os.Environ()
+	}
+	separator := ":"
+	if goos == "windows" {
+		separator = ";"
+	}
+	key, path := "PATH", ""
+	for _, e := range environ {
+		k, v, _ := strings.Cut(e, "=")
+		if k == "PATH" || (goos == "windows" && strings.EqualFold(k, "PATH")) {
+			key, path = k, v
+		}
+	}
+	value := strings.Join(dirs, separator)
+	if path != "" {
+		value += separator + path
+	}
+	// Later values override earlier ones, so the new PATH is appended
+	return append(slices.Clip(environ), key+"="+value)
+}
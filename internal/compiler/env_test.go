@@ -0,0 +1,76 @@
+package compiler
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestEnvironToVars(t *testing.T) {
+	t.Parallel()
+
+	environ := []string{"Path=/usr/bin", "HOME=/home/task", "EMPTY="}
+
+	windows := environToVars(environ, "windows")
+	assert.Equal(t, []string{"PATH", "HOME", "EMPTY"}, windows.Keys())
+	assert.Equal(t, "/usr/bin", windows.Get("PATH").Value)
+
+	linux := environToVars(environ, "linux")
+	assert.Equal(t, []string{"Path", "HOME", "EMPTY"}, linux.Keys())
+	assert.Equal(t, "/usr/bin", linux.Get("Path").Value)
+	assert.False(t, linux.Exists("PATH"))
+	assert.Equal(t, "", linux.Get("EMPTY").Value)
+}
+
+func TestEnvironToVarsDuplicates(t *testing.T) {
+	t.Parallel()
+
+	environ := []string{"Path=/usr/bin", "FOO=1", "PATH=/bin", "FOO=2"}
+
+	windows := environToVars(environ, "windows")
+	assert.Equal(t, []string{"PATH", "FOO"}, windows.Keys())
+	assert.Equal(t, "/bin", windows.Get("PATH").Value)
+	assert.Equal(t, "2", windows.Get("FOO").Value)
+
+	linux := environToVars(environ, "linux")
+	assert.Equal(t, []string{"Path", "FOO", "PATH"}, linux.Keys())
+	assert.Equal(t, "/usr/bin", linux.Get("Path").Value)
+	assert.Equal(t, "/bin", linux.Get("PATH").Value)
+	assert.Equal(t, "2", linux.Get("FOO").Value)
+}
+
+func TestFilterEnvVars(t *testing.T) {
+	t.Parallel()
+
+	environ := []string{"Path=/usr/bin", "TASK_ENV=dev", "TASK_TOKEN=secret", "HOME=/home/task"}
+
+	linux := filterEnvVars(environToVars(environ, "linux"), []string{"TASK_*", "HOME"}, nil, "linux")
+	assert.Equal(t, []string{"TASK_ENV", "TASK_TOKEN", "HOME"}, linux.Keys())
+
+	windows := filterEnvVars(environToVars(environ, "windows"), []string{"task_env", "Path"}, nil, "windows")
+	assert.Equal(t, []string{"PATH", "TASK_ENV"}, windows.Keys())
+
+	denied := filterEnvVars(environToVars(environ, "linux"), []string{"TASK_*"}, []string{"TASK_TOKEN"}, "linux")
+	assert.Equal(t, []string{"TASK_ENV"}, denied.Keys())
+
+	denied = filterEnvVars(environToVars(environ, "windows"), nil, []string{"path", "TASK_*"}, "windows")
+	assert.Equal(t, []string{"HOME"}, denied.Keys())
+
+	all := filterEnvVars(environToVars(environ, "linux"), nil, nil, "linux")
+	assert.Equal(t, []string{"Path", "TASK_ENV", "TASK_TOKEN", "HOME"}, all.Keys())
+}
+
+func TestPrependPath(t *testing.T) {
+	t.Parallel()
+
+	environ := []string{"Path=/usr/bin", "HOME=/home/task"}
+
+	linux := prependPath(environ, []string{"/opt/a", "/opt/b"}, "linux")
+	assert.Equal(t, []string{"Path=/usr/bin", "HOME=/home/task", "PATH=/opt/a:/opt/b"}, linux)
+
+	windows := prependPath(environ, []string{`C:\a`, `C:\b`}, "windows")
+	assert.Equal(t, []string{"Path=/usr/bin", "HOME=/home/task", `Path=C:\a;C:\b;/usr/bin`}, windows)
+
+	assert.Equal(t, environ, prependPath(environ, nil, "linux"))
+	assert.Equal(t, []string{"Path=/usr/bin", "HOME=/home/task"}, environ)
+}
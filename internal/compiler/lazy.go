@@ -0,0 +1,129 @@
+package compiler
+
+import (
+	"cmp"
+	"context"
+	"slices"
+
+	"github.com/go-task/task/v3/internal/env"
+	"github.com/go-task/task/v3/taskfile/ast"
+)
+
+// isPendingLazyVar reports whether v is a lazy dynamic variable whose command
+// hasn't run yet.
+func isPendingLazyVar(v ast.Var) bool {
+	return v.Lazy && v.Value == nil && v.Sh != nil
+}
+
+// pendingLazyVars returns the names of the lazy dynamic variables of vars
+// whose command hasn't run yet.
+func pendingLazyVars(vars *ast.Vars) []string {
+	var names []string
+	_ = vars.Range(func(k string, v ast.Var) error {
+		if isPendingLazyVar(v) {
+			names = append(names, k)
+		}
+		return nil
+	})
+	return names
+}
+
+// ResolveLazyVars runs the commands of the given lazy dynamic variables of
+// vars, if they haven't run yet, and sets their values. Other names are
+// ignored. Like other dynamic variables, their results are cached.
+func (c *Compiler) ResolveLazyVars(ctx context.Context, vars *ast.Vars, names ...string) error {
+	for _, name := range pendingLazyVars(vars) {
+		if !slices.Contains(names, name) {
+			continue
+		}
+		v := vars.Get(name)
+		// The directory of the command was resolved when the variable was
+		// declared
+		static, err := c.HandleNamedDynamicVar(ctx, name, v, "", env.FromVars(vars))
+		if err != nil {
+			return err
+		}
+		if err := c.setVar(vars, name, v.Merge, ast.Var{Value: static, Secret: v.Secret, Source: v.Source, Dynamic: true}); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// resolveLazyRefs resolves the lazy dynamic variables of vars referenced by v.
+func (c *Compiler) resolveLazyRefs(ctx context.Context, vars *ast.Vars, v ast.Var) error {
+	names := pendingLazyVars(vars)
+	if len(names) == 0 {
+		return nil
+	}
+	// A variable referencing its own name refers to the value it had before,
+	// which is the lazy variable it overrides
+	return c.ResolveLazyVars(ctx, vars, newRefMatcher(names, c.Delims).references(v, "")...)
+}
+
+// LazyVarRefs returns the names of the lazy dynamic variables of vars that
+// are referenced by t, whether in a template (e.g. {{.FOO}}), in the variables
+// and environment of the task and of its calls, or as the variable of a loop.
+func (c *Compiler) LazyVarRefs(vars *ast.Vars, t *ast.Task) []string {
+	names := pendingLazyVars(vars)
+	if len(names) == 0 {
+		return nil
+	}
+	m := newRefMatcher(names, cmp.Or(t.Delims, c.Delims))
+
+	var refs []string
+	add := func(found ...string) {
+		for _, name := range found {
+			if !slices.Contains(refs, name) {
+				refs = append(refs, name)
+			}
+		}
+	}
+	addTemplates := func(templates ...string) {
+		for _, s := range templates {
+			add(m.templateRefs(s)...)
+		}
+	}
+	addVars := func(vars *ast.Vars) {
+		_ = vars.Range(func(k string, v ast.Var) error {
+			add(m.references(v, "")...)
+			return nil
+		})
+	}
+	addFor := func(f *ast.For) {
+		if f == nil {
+			return
+		}
+		if slices.Contains(names, f.Var) {
+			add(f.Var)
+		}
+		for _, item := range f.List {
+			if s, ok := item.(string); ok {
+				addTemplates(s)
+			}
+		}
+	}
+
+	addTemplates(t.Label, t.Desc, t.Summary, t.Dir, t.Method, t.Prefix, t.Run)
+	addTemplates(t.Prompt...)
+	addTemplates(t.Dotenv...)
+	addTemplates(t.Status...)
+	for _, g := range slices.Concat(t.Sources, t.Generates) {
+		addTemplates(g.Glob)
+	}
+	for _, p := range t.Preconditions {
+		addTemplates(p.Sh, p.Msg)
+	}
+	for _, cmd := range t.Cmds {
+		addTemplates(cmd.Cmd, cmd.Task)
+		addVars(cmd.Vars)
+		addFor(cmd.For)
+	}
+	for _, dep := range t.Deps {
+		addTemplates(dep.Task)
+		addVars(dep.Vars)
+		addFor(dep.For)
+	}
+	addVars(t.Env)
+	return refs
+}
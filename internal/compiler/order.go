@@ -0,0 +1,182 @@
+package compiler
+
+import (
+	"cmp"
+	"fmt"
+	"regexp"
+	"slices"
+	"strings"
+	"sync"
+
+	"github.com/go-task/task/v3/taskfile/ast"
+)
+
+// orderVars returns vars in the order they should be resolved in. Variables
+// are resolved in declaration order, except that a variable referencing
+// another variable declared after it is resolved after that variable. This is
+// done in passes over the variables that are not resolved yet. Each pass must
+// resolve at least one of them; otherwise, the remaining variables reference
+// each other and an error describing the cycle is returned.
+//
+// A variable referencing its own name refers to the value it had before (e.g.
+// a global variable or an environment variable), so it doesn't depend on
+// itself.
+func orderVars(vars *ast.Vars, delims ast.Delims) (*ast.Vars, error) {
+	if vars.Len() < 2 {
+		return vars, nil
+	}
+
+	names := vars.Keys()
+	m := newRefMatcher(names, delims)
+	deps := make(map[string][]string, len(names))
+	for _, name := range names {
+		deps[name] = m.references(vars.Get(name), name)
+	}
+
+	ordered := make([]string, 0, len(names))
+	resolved := make(map[string]bool, len(names))
+	pending := names
+	for len(pending) > 0 {
+		var next []string
+		for _, name := range pending {
+			if slices.ContainsFunc(deps[name], func(dep string) bool { return !resolved[dep] }) {
+				next = append(next, name)
+				continue
+			}
+			ordered = append(ordered, name)
+			resolved[name] = true
+		}
+		if len(next) == len(pending) {
+			return nil, fmt.Errorf("task: Cycle detected in variables: %s", strings.Join(findCycle(next, deps), " -> "))
+		}
+		pending = next
+	}
+
+	if slices.Equal(ordered, names) {
+		return vars, nil
+	}
+	result := &ast.Vars{}
+	for _, name := range ordered {
+		result.Set(name, vars.Get(name))
+	}
+	return result, nil
+}
+
+// findCycle returns a cycle between the given variables, starting and ending
+// with the same variable. Each variable must depend on at least one of the
+// others, so following their dependencies always leads to a cycle.
+func findCycle(names []string, deps map[string][]string) []string {
+	var path []string
+	visited := make(map[string]int, len(names))
+	name := names[0]
+	for {
+		if i, ok := visited[name]; ok {
+			return append(path[i:], name)
+		}
+		visited[name] = len(path)
+		path = append(path, name)
+		for _, dep := range deps[name] {
+			if slices.Contains(names, dep) {
+				name = dep
+				break
+			}
+		}
+	}
+}
+
+// fieldRefRegex, indexRefRegex and shellRefRegex match the names referenced
+// as a field (e.g. .FOO), with the index function (e.g. index . "FOO") and as a
+// shell variable (e.g. $FOO or ${FOO}).
+var (
+	fieldRefRegex = regexp.MustCompile(`\.([\p{L}_][\p{L}\p{Nd}_]*)`)
+	indexRefRegex = regexp.MustCompile(`\bindex\s+[.$]\s+"([^"]+)"`)
+	shellRefRegex = regexp.MustCompile(`\$\{?([\p{L}_][\p{L}\p{Nd}_]*)`)
+)
+
+// actionRegexps caches the regexps matching the template actions, per pair of
+// delimiters, since they are needed for every layer of variables of every
+// task.
+var actionRegexps sync.Map
+
+func actionRegexp(delims ast.Delims) *regexp.Regexp {
+	if re, ok := actionRegexps.Load(delims); ok {
+		return re.(*regexp.Regexp)
+	}
+	re := regexp.MustCompile(`(?s)` +
+		regexp.QuoteMeta(cmp.Or(delims.Left, "{{")) + `(.*?)` +
+		regexp.QuoteMeta(cmp.Or(delims.Right, "}}")))
+	actual, _ := actionRegexps.LoadOrStore(delims, re)
+	return actual.(*regexp.Regexp)
+}
+
+// refMatcher finds which variables of a list are referenced by a variable.
+// Variables declared with their own delimiters are matched with them instead
+// of the default ones of the matcher.
+type refMatcher struct {
+	names  map[string]bool
+	delims ast.Delims
+}
+
+func newRefMatcher(names []string, delims ast.Delims) *refMatcher {
+	m := make(map[string]bool, len(names))
+	for _, name := range names {
+		m[name] = true
+	}
+	return &refMatcher{names: m, delims: delims}
+}
+
+// references returns the names referenced by v, other than its own name.
+// Names are referenced as a field (e.g. {{.FOO}}) or with the index function
+// (e.g. {{index . "FOO"}}) inside the template actions of its value, command,
+// stdin, file, glob and directory, as a shell variable (e.g. $FOO) in its
+// command, or as its "when" condition.
+func (m *refMatcher) references(v ast.Var, self string) []string {
+	var value, sh string
+	if s, ok := v.Value.(string); ok {
+		value = s
+	} else if v.Value != nil {
+		value = fmt.Sprint(v.Value)
+	}
+	if v.Sh != nil {
+		sh = *v.Sh
+	}
+
+	var refs []string
+	add := func(matches [][]string) {
+		for _, match := range matches {
+			if name := match[1]; m.names[name] && name != self && !slices.Contains(refs, name) {
+				refs = append(refs, name)
+			}
+		}
+	}
+	if v.Ref != "" {
+		add(fieldRefRegex.FindAllStringSubmatch(v.Ref, -1))
+	}
+	actionRe := actionRegexp(cmp.Or(v.Delims, m.delims))
+	for _, s := range []string{value, sh, v.Stdin, v.File, v.Glob, v.Dir} {
+		for _, action := range actionRe.FindAllStringSubmatch(s, -1) {
+			add(fieldRefRegex.FindAllStringSubmatch(action[1], -1))
+			add(indexRefRegex.FindAllStringSubmatch(action[1], -1))
+		}
+	}
+	add(shellRefRegex.FindAllStringSubmatch(sh, -1))
+	if v.When != "" && v.When != self && !slices.Contains(refs, v.When) && m.names[v.When] {
+		refs = append(refs, v.When)
+	}
+	return refs
+}
+
+// templateRefs returns the names referenced as a field, or with the index
+// function, inside the template actions of s.
+func (m *refMatcher) templateRefs(s string) []string {
+	var refs []string
+	for _, action := range actionRegexp(m.delims).FindAllStringSubmatch(s, -1) {
+		matches := slices.Concat(fieldRefRegex.FindAllStringSubmatch(action[1], -1), indexRefRegex.FindAllStringSubmatch(action[1], -1))
+		for _, match := range matches {
+			if m.names[match[1]] && !slices.Contains(refs, match[1]) {
+				refs = append(refs, match[1])
+			}
+		}
+	}
+	return refs
+}
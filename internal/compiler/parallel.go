@@ -0,0 +1,118 @@
+package compiler
+
+import (
+	"cmp"
+	"context"
+	"regexp"
+	"runtime"
+
+	"golang.org/x/sync/errgroup"
+
+	"github.com/go-task/task/v3/internal/env"
+	"github.com/go-task/task/v3/internal/templater"
+	"github.com/go-task/task/v3/taskfile/ast"
+)
+
+type namedVar struct {
+	name string
+	v    ast.Var
+}
+
+// batchVars splits vars into batches that can be resolved concurrently. Each
+// batch is either a single variable or a run of consecutive dynamic variables
+// whose commands, stdin and directories don't mention the name of any other
+// variable in the batch, either as a template (e.g. {{.FOO}}) or as a shell
+// variable (e.g. $FOO).
+func batchVars(vars *ast.Vars) [][]namedVar {
+	matchers := nameMatchers{}
+	var batches [][]namedVar
+	var current []namedVar
+	flush := func() {
+		if len(current) > 0 {
+			batches = append(batches, current)
+			current = nil
+		}
+	}
+	_ = vars.Range(func(k string, v ast.Var) error {
+		if !isUnresolvedDynamicVar(v) {
+			flush()
+			batches = append(batches, []namedVar{{name: k, v: v}})
+			return nil
+		}
+		for _, other := range current {
+			if matchers.mentions(v, other.name) || matchers.mentions(other.v, k) {
+				flush()
+				break
+			}
+		}
+		current = append(current, namedVar{name: k, v: v})
+		return nil
+	})
+	flush()
+	return batches
+}
+
+func isUnresolvedDynamicVar(v ast.Var) bool {
+	return v.Value == nil && v.Ref == "" && v.Sh != nil && *v.Sh != "" && !v.Lazy && !v.Export && v.When == ""
+}
+
+// nameMatchers holds the regexps matching the names of variables, compiled
+// once per name.
+type nameMatchers map[string]*regexp.Regexp
+
+// mentions reports whether the command, the stdin or the directory of v
+// contains name as a whole word. This is deliberately conservative: a false
+// positive only means that two variables are resolved one after the other
+// instead of concurrently.
+func (m nameMatchers) mentions(v ast.Var, name string) bool {
+	re, ok := m[name]
+	if !ok {
+		re = regexp.MustCompile(`\b` + regexp.QuoteMeta(name) + `\b`)
+		m[name] = re
+	}
+	return re.MatchString(*v.Sh) || re.MatchString(v.Stdin) || re.MatchString(v.Dir)
+}
+
+// resolveDynamicVarsInParallel resolves a batch of independent dynamic
+// variables concurrently and sets their values into result in declaration
+// order. If several commands fail, the error of the first variable declared is
+// returned.
+func (c *Compiler) resolveDynamicVarsInParallel(ctx context.Context, batch []namedVar, dir string, layer ast.VarSource, result *ast.Vars) error {
+	cache := &templater.Cache{Vars: result, Funcs: c.funcs(ctx), Strict: c.StrictTemplates}
+	newVars := make([]ast.Var, len(batch))
+	for i, nv := range batch {
+		c.logOverride(result, nv.name, cmp.Or(nv.v.Source, layer))
+		cache.Delims = cmp.Or(nv.v.Delims, c.Delims)
+		newVars[i] = templater.ReplaceVar(nv.v, cache)
+		if err := cache.Err(); err != nil {
+			templater.SetErrVar(err, nv.name)
+			return err
+		}
+	}
+
+	environ := env.FromVars(result)
+	values := make([]any, len(batch))
+	errs := make([]error, len(batch))
+
+	var g errgroup.Group
+	g.SetLimit(runtime.NumCPU())
+	for i := range batch {
+		g.Go(func() error {
+			values[i], errs[i] = c.HandleNamedDynamicVar(ctx, batch[i].name, newVars[i], dir, environ)
+			return nil
+		})
+	}
+	_ = g.Wait()
+
+	for _, err := range errs {
+		if err != nil {
+			return err
+		}
+	}
+	for i, nv := range batch {
+		if err := c.setVar(result, nv.name, newVars[i].Merge, ast.Var{Value: values[i], Secret: newVars[i].Secret, Source: cmp.Or(nv.v.Source, layer), Dynamic: true}); err != nil {
+			return err
+		}
+	}
+	return nil
+}
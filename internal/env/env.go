@@ -12,9 +12,19 @@ func Get(t *ast.Task) []string {
 	if t.Env == nil {
 		return nil
 	}
+	return FromVars(t.Env)
+}
+
+// FromVars returns the current environment with the given variables added to
+// it, in the order they are declared. Unless the EnvPrecedence experiment is
+// enabled, variables that are already set in the environment are not
+// overridden.
+func FromVars(vars *ast.Vars) []string {
 	environ := os.Environ()
-	for k, v := range t.Env.ToCacheMap() {
-		if !isTypeAllowed(v) {
+	values := vars.ToCacheMap()
+	for _, k := range vars.Keys() {
+		v, ok := values[k]
+		if !ok || !isTypeAllowed(v) {
 			continue
 		}
 		if !experiments.EnvPrecedence.Enabled {
@@ -0,0 +1,33 @@
+package env
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/go-task/task/v3/taskfile/ast"
+)
+
+func TestFromVars(t *testing.T) {
+	t.Parallel()
+
+	vars := &ast.Vars{}
+	vars.Set("TASK_TEST_ZED", ast.Var{Value: "z"})
+	vars.Set("TASK_TEST_ALPHA", ast.Var{Value: "a"})
+	vars.Set("TASK_TEST_LIST", ast.Var{Value: []any{"a"}})
+	vars.Set("TASK_TEST_NUMBER", ast.Var{Value: 1})
+	vars.Set("TASK_TEST_DYNAMIC", ast.Var{Sh: ptr("echo dynamic")})
+
+	for range 10 {
+		environ := FromVars(vars)
+		assert.Equal(t, []string{
+			"TASK_TEST_ZED=z",
+			"TASK_TEST_ALPHA=a",
+			"TASK_TEST_NUMBER=1",
+		}, environ[len(environ)-3:])
+	}
+}
+
+func ptr[T any](v T) *T {
+	return &v
+}
@@ -6,6 +6,7 @@ import (
 	"fmt"
 	"io"
 	"os"
+	"os/exec"
 	"path/filepath"
 	"strings"
 	"time"
@@ -19,6 +20,7 @@ import (
 // RunCommandOptions is the options for the RunCommand func
 type RunCommandOptions struct {
 	Command   string
+	Shell     string
 	Dir       string
 	Env       []string
 	PosixOpts []string
@@ -31,12 +33,21 @@ type RunCommandOptions struct {
 // ErrNilOptions is returned when a nil options is given
 var ErrNilOptions = errors.New("execext: nil options given")
 
+// ErrShellNotFound is returned when the given shell can't be found in PATH
+var ErrShellNotFound = errors.New("execext: shell not found")
+
 // RunCommand runs a shell command
 func RunCommand(ctx context.Context, opts *RunCommandOptions) error {
 	if opts == nil {
 		return ErrNilOptions
 	}
 
+	// If a specific shell was requested, hand the command over to it instead
+	// of interpreting it ourselves
+	if opts.Shell != "" {
+		return runShell(ctx, opts)
+	}
+
 	// Set "-e" or "errexit" by default
 	opts.PosixOpts = append(opts.PosixOpts, "e")
 
@@ -90,6 +101,40 @@ func RunCommand(ctx context.Context, opts *RunCommandOptions) error {
 	return r.Run(ctx, p)
 }
 
+// shellWaitDelay is how long runShell waits for the output of a command to be
+// closed once it is killed.
+const shellWaitDelay = time.Second
+
+// runShell runs the command with the shell given in the options, passing it
+// via "-c" so that it reaches the shell exactly as written
+func runShell(ctx context.Context, opts *RunCommandOptions) error {
+	path, err := exec.LookPath(opts.Shell)
+	if err != nil {
+		return fmt.Errorf("%w: %q", ErrShellNotFound, opts.Shell)
+	}
+
+	cmd := exec.CommandContext(ctx, path, "-c", opts.Command)
+	cmd.Dir = opts.Dir
+	if len(opts.Env) > 0 {
+		cmd.Env = opts.Env
+	}
+	cmd.Stdin = opts.Stdin
+	cmd.Stdout = opts.Stdout
+	cmd.Stderr = opts.Stderr
+	killProcessGroup(cmd)
+	// Don't wait for processes that left the group, but still hold the
+	// output, once the command is killed
+	cmd.WaitDelay = shellWaitDelay
+
+	// Report exit codes the same way as commands run by the interpreter
+	err = cmd.Run()
+	var exitErr *exec.ExitError
+	if errors.As(err, &exitErr) && exitErr.ExitCode() >= 0 {
+		return interp.NewExitStatus(uint8(exitErr.ExitCode()))
+	}
+	return err
+}
+
 // Expand is a helper to mvdan.cc/shell.Fields that returns the first field
 // if available.
 func Expand(s string) (string, error) {
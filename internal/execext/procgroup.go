@@ -0,0 +1,18 @@
+//go:build !windows
+
+package execext
+
+import (
+	"os/exec"
+	"syscall"
+)
+
+// killProcessGroup makes the command run in its own process group, which is
+// killed as a whole when the context of the command is done, so that the
+// processes started by the shell don't outlive it.
+func killProcessGroup(cmd *exec.Cmd) {
+	cmd.SysProcAttr = &syscall.SysProcAttr{Setpgid: true}
+	cmd.Cancel = func() error {
+		return syscall.Kill(-cmd.Process.Pid, syscall.SIGKILL)
+	}
+}
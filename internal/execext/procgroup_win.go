@@ -0,0 +1,9 @@
+//go:build windows
+
+package execext
+
+import "os/exec"
+
+// NOTE: On Windows, only the shell process is killed when the context of the
+// command is done, since there are no process groups to signal.
+func killProcessGroup(cmd *exec.Cmd) {}
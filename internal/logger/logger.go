@@ -2,11 +2,14 @@ package logger
 
 import (
 	"bufio"
+	"cmp"
+	"fmt"
 	"io"
 	"os"
 	"slices"
 	"strconv"
 	"strings"
+	"sync"
 
 	"github.com/fatih/color"
 
@@ -117,6 +120,36 @@ type Logger struct {
 	Color      bool
 	AssumeYes  bool
 	AssumeTerm bool // Used for testing
+
+	secretsMutex sync.RWMutex
+	secrets      []string
+}
+
+// AddSecret makes the logger print "***" instead of value.
+func (l *Logger) AddSecret(value string) {
+	if value == "" {
+		return
+	}
+	l.secretsMutex.Lock()
+	defer l.secretsMutex.Unlock()
+	if slices.Contains(l.secrets, value) {
+		return
+	}
+	l.secrets = append(l.secrets, value)
+	// Replace the longest secrets first, in case a secret contains another one
+	slices.SortFunc(l.secrets, func(a, b string) int {
+		return cmp.Compare(len(b), len(a))
+	})
+}
+
+// Redact replaces the secrets added to the logger by "***" in s.
+func (l *Logger) Redact(s string) string {
+	l.secretsMutex.RLock()
+	defer l.secretsMutex.RUnlock()
+	for _, secret := range l.secrets {
+		s = strings.ReplaceAll(s, secret, "***")
+	}
+	return s
 }
 
 // Outf prints stuff to STDOUT.
@@ -133,7 +166,7 @@ func (l *Logger) FOutf(w io.Writer, color Color, s string, args ...any) {
 		color = Default
 	}
 	print := color()
-	print(w, s, args...)
+	print(w, "%s", l.Redact(fmt.Sprintf(s, args...)))
 }
 
 // VerboseOutf prints stuff to STDOUT if verbose mode is enabled.
@@ -152,7 +185,7 @@ func (l *Logger) Errf(color Color, s string, args ...any) {
 		color = Default
 	}
 	print := color()
-	print(l.Stderr, s, args...)
+	print(l.Stderr, "%s", l.Redact(fmt.Sprintf(s, args...)))
 }
 
 // VerboseErrf prints stuff to STDERR if verbose mode is enabled.
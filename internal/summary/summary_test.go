@@ -22,14 +22,14 @@ func TestPrintsDependenciesIfPresent(t *testing.T) {
 		},
 	}
 
-	summary.PrintTask(&l, task)
+	summary.PrintTask(l, task)
 
 	assert.Contains(t, buffer.String(), "\ndependencies:\n - dep1\n - dep2\n - dep3\n")
 }
 
-func createDummyLogger() (*bytes.Buffer, logger.Logger) {
+func createDummyLogger() (*bytes.Buffer, *logger.Logger) {
 	buffer := &bytes.Buffer{}
-	l := logger.Logger{
+	l := &logger.Logger{
 		Stderr:  buffer,
 		Stdout:  buffer,
 		Verbose: false,
@@ -43,7 +43,7 @@ func TestDoesNotPrintDependenciesIfMissing(t *testing.T) {
 		Deps: []*ast.Dep{},
 	}
 
-	summary.PrintTask(&l, task)
+	summary.PrintTask(l, task)
 
 	assert.NotContains(t, buffer.String(), "dependencies:")
 }
@@ -54,7 +54,7 @@ func TestPrintTaskName(t *testing.T) {
 		Task: "my-task-name",
 	}
 
-	summary.PrintTask(&l, task)
+	summary.PrintTask(l, task)
 
 	assert.Contains(t, buffer.String(), "task: my-task-name\n")
 }
@@ -69,7 +69,7 @@ func TestPrintTaskCommandsIfPresent(t *testing.T) {
 		},
 	}
 
-	summary.PrintTask(&l, task)
+	summary.PrintTask(l, task)
 
 	assert.Contains(t, buffer.String(), "\ncommands:\n")
 	assert.Contains(t, buffer.String(), "\n - command-1\n")
@@ -83,7 +83,7 @@ func TestDoesNotPrintCommandIfMissing(t *testing.T) {
 		Cmds: []*ast.Cmd{},
 	}
 
-	summary.PrintTask(&l, task)
+	summary.PrintTask(l, task)
 
 	assert.NotContains(t, buffer.String(), "commands")
 }
@@ -101,7 +101,7 @@ func TestLayout(t *testing.T) {
 		},
 	}
 
-	summary.PrintTask(&l, task)
+	summary.PrintTask(l, task)
 
 	assert.Equal(t, expectedOutput(), buffer.String())
 }
@@ -134,17 +134,17 @@ func TestPrintDescriptionAsFallback(t *testing.T) {
 	}
 	taskWithoutSummaryOrDescription := &ast.Task{}
 
-	summary.PrintTask(&l, taskWithoutSummary)
+	summary.PrintTask(l, taskWithoutSummary)
 
 	assert.Contains(t, buffer.String(), "description")
 
 	buffer.Reset()
-	summary.PrintTask(&l, taskWithSummary)
+	summary.PrintTask(l, taskWithSummary)
 
 	assert.NotContains(t, buffer.String(), "description")
 
 	buffer.Reset()
-	summary.PrintTask(&l, taskWithoutSummaryOrDescription)
+	summary.PrintTask(l, taskWithoutSummaryOrDescription)
 
 	assert.Contains(t, buffer.String(), "\n(task does not have description or summary)\n")
 }
@@ -161,7 +161,7 @@ func TestPrintAllWithSpaces(t *testing.T) {
 	tasks.Set("t2", t2)
 	tasks.Set("t3", t3)
 
-	summary.PrintTasks(&l,
+	summary.PrintTasks(l,
 		&ast.Taskfile{Tasks: tasks},
 		[]*ast.Call{{Task: "t1"}, {Task: "t2"}, {Task: "t3"}})
 
@@ -1,15 +1,33 @@
 package templater
 
 import (
+	"bytes"
+	"cmp"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"maps"
+	"net/url"
+	"os"
 	"path/filepath"
+	"reflect"
 	"runtime"
+	"slices"
+	"strconv"
 	"strings"
+	"time"
 
 	"github.com/davecgh/go-spew/spew"
+	"gopkg.in/yaml.v3"
 	"mvdan.cc/sh/v3/shell"
 	"mvdan.cc/sh/v3/syntax"
 
 	sprig "github.com/go-task/slim-sprig/v3"
+	"github.com/go-task/task/v3/errors"
+	"github.com/go-task/task/v3/internal/filepathext"
+	"github.com/go-task/task/v3/internal/fingerprint"
 	"github.com/go-task/template"
 )
 
@@ -20,13 +38,24 @@ func init() {
 		"OS":     func() string { return runtime.GOOS },
 		"ARCH":   func() string { return runtime.GOARCH },
 		"numCPU": func() int { return runtime.NumCPU() },
+		"hostname": func() (string, error) {
+			return os.Hostname()
+		},
+		"userHomeDir": func() (string, error) {
+			return os.UserHomeDir()
+		},
 		"catLines": func(s string) string {
 			s = strings.ReplaceAll(s, "\r\n", " ")
 			return strings.ReplaceAll(s, "\n", " ")
 		},
-		"splitLines": func(s string) []string {
-			s = strings.ReplaceAll(s, "\r\n", "\n")
-			return strings.Split(s, "\n")
+		"splitLines": SplitLines,
+		"line":       line,
+		"toLF":       toLF,
+		"toCRLF":     toCRLF,
+		"osNewline":  osNewline,
+		"indentVar":  indentVar,
+		"nindentVar": func(spaces int, v any) string {
+			return "\n" + indentVar(spaces, v)
 		},
 		"fromSlash": func(path string) string {
 			return filepath.FromSlash(path)
@@ -34,6 +63,11 @@ func init() {
 		"toSlash": func(path string) string {
 			return filepath.ToSlash(path)
 		},
+		"expandPath":    expandPath,
+		"osPathJoin":    osPathJoin,
+		"mustAtoi":      mustAtoi,
+		"mustAtof":      mustAtof,
+		"firstNonEmpty": firstNonEmpty,
 		"exeExt": func() string {
 			if runtime.GOOS == "windows" {
 				return ".exe"
@@ -43,6 +77,14 @@ func init() {
 		"shellQuote": func(str string) (string, error) {
 			return syntax.Quote(str, syntax.LangBash)
 		},
+		"shQuote": func(str string) (string, error) {
+			return syntax.Quote(str, syntax.LangPOSIX)
+		},
+		"psQuote":          psQuote,
+		"urlQueryEscape":   url.QueryEscape,
+		"urlQueryUnescape": url.QueryUnescape,
+		"urlPathEscape":    url.PathEscape,
+		"urlPathUnescape":  url.PathUnescape,
 		"splitArgs": func(s string) ([]string, error) {
 			return shell.Fields(s, nil)
 		},
@@ -73,6 +115,34 @@ func init() {
 		"spew": func(v any) string {
 			return spew.Sdump(v)
 		},
+		"fromJson": func(v string) (any, error) {
+			var output any
+			err := json.Unmarshal([]byte(v), &output)
+			return output, err
+		},
+		"toYaml": func(v any) (string, error) {
+			var b bytes.Buffer
+			enc := yaml.NewEncoder(&b)
+			enc.SetIndent(2)
+			if err := enc.Encode(v); err != nil {
+				return "", err
+			}
+			if err := enc.Close(); err != nil {
+				return "", err
+			}
+			return strings.TrimSuffix(b.String(), "\n"), nil
+		},
+		"envDefault": func(name, fallback string) string {
+			if value := os.Getenv(name); value != "" {
+				return value
+			}
+			return fallback
+		},
+		"fromYaml": func(v string) (any, error) {
+			var output any
+			err := yaml.Unmarshal([]byte(v), &output)
+			return output, err
+		},
 	}
 
 	// aliases
@@ -87,4 +157,381 @@ func init() {
 	for k, v := range taskFuncs {
 		templateFuncs[k] = v
 	}
+	for k, v := range stringFuncs {
+		templateFuncs[k] = v
+	}
+	for k, v := range fileFuncs("") {
+		templateFuncs[k] = v
+	}
+	for k, v := range RunTimeFuncs(time.Now()) {
+		templateFuncs[k] = v
+	}
+}
+
+// stringFuncs are string functions commonly used in Taskfiles. They are
+// provided by Task itself, under names prefixed with "str" so they don't
+// collide with slim-sprig's, and keep working regardless of the slim-sprig
+// version. They take the same arguments as their slim-sprig counterparts.
+var stringFuncs = template.FuncMap{
+	"strTrim":       strings.TrimSpace,
+	"strTrimPrefix": func(prefix, s string) string { return strings.TrimPrefix(s, prefix) },
+	"strTrimSuffix": func(suffix, s string) string { return strings.TrimSuffix(s, suffix) },
+	"strReplace":    func(old, new, s string) string { return strings.ReplaceAll(s, old, new) },
+	"strUpper":      strings.ToUpper,
+	"strLower":      strings.ToLower,
+	"strRepeat":     func(count int, s string) string { return strings.Repeat(s, count) },
+	"strContains":   func(substr, s string) bool { return strings.Contains(s, substr) },
+	"strHasPrefix":  func(prefix, s string) bool { return strings.HasPrefix(s, prefix) },
+	"strHasSuffix":  func(suffix, s string) bool { return strings.HasSuffix(s, suffix) },
+}
+
+// NewFuncs returns the functions available to templates, with relative paths
+// given to file functions (e.g. readFile, fileExists) resolved from the given
+// directory.
+func NewFuncs(dir string) template.FuncMap {
+	funcs := maps.Clone(templateFuncs)
+	maps.Copy(funcs, fileFuncs(dir))
+	return funcs
+}
+
+// SafeFuncNames are the names of the functions available to templates when
+// only safe functions are allowed. They don't access the environment, files
+// or the network, and don't depend on the current time or on randomness.
+var SafeFuncNames = []string{
+	// Task functions
+	"OS", "ARCH", "exeExt", "catLines", "splitLines", "line", "toLF", "toCRLF",
+	"osNewline", "indentVar", "nindentVar", "fromSlash",
+	"toSlash", "osPathJoin", "shellQuote", "q", "shQuote", "psQuote",
+	"splitArgs", "joinPath", "relPath", "merge", "spew", "fromJson", "toYaml",
+	"fromYaml", "mustAtoi", "mustAtof", "firstNonEmpty", "strTrim",
+	"strTrimPrefix", "strTrimSuffix", "strReplace", "strUpper", "strLower",
+	"strRepeat", "strContains", "strHasPrefix", "strHasSuffix",
+	// Strings
+	"trim", "trimAll", "trimPrefix", "trimSuffix", "upper", "lower", "title",
+	"repeat", "substr", "trunc", "contains", "hasPrefix", "hasSuffix",
+	"quote", "squote", "cat", "indent", "nindent", "replace", "plural",
+	"split", "splitList", "splitn", "join", "sortAlpha", "toString", "toStrings",
+	// Regular expressions
+	"regexMatch", "regexFind", "regexFindAll", "regexReplaceAll",
+	"regexReplaceAllLiteral", "regexSplit", "regexQuoteMeta",
+	// Math
+	"add", "add1", "sub", "div", "mod", "mul", "max", "min", "maxf", "minf",
+	"floor", "ceil", "round", "seq", "until", "untilStep",
+	// Conversions and type checks
+	"atoi", "int", "int64", "float64", "toDecimal", "kindOf", "kindIs",
+	"typeOf", "typeIs", "typeIsLike", "deepEqual",
+	// Defaults and conditions
+	"default", "empty", "coalesce", "all", "any", "ternary", "fail",
+	// Encodings
+	"b64enc", "b64dec", "b32enc", "b32dec", "toJson", "toPrettyJson",
+	"toRawJson",
+	// Lists
+	"list", "tuple", "first", "rest", "last", "initial", "append", "push",
+	"prepend", "concat", "reverse", "uniq", "without", "has", "compact",
+	"slice", "chunk",
+	// Dictionaries
+	"dict", "get", "set", "unset", "hasKey", "pluck", "dig", "keys", "pick",
+	"omit", "values",
+	// Paths and URLs
+	"base", "dir", "clean", "ext", "isAbs", "osBase", "osClean", "osDir",
+	"osExt", "osIsAbs", "urlParse", "urlJoin", "urlQueryEscape",
+	"urlQueryUnescape", "urlPathEscape", "urlPathUnescape",
+}
+
+// NewSafeFuncs returns the functions listed in SafeFuncNames.
+func NewSafeFuncs() template.FuncMap {
+	funcs := make(template.FuncMap, len(SafeFuncNames))
+	for _, name := range SafeFuncNames {
+		funcs[name] = templateFuncs[name]
+	}
+	return funcs
+}
+
+// SplitLines splits s into lines, handling both Unix (\n) and Windows (\r\n)
+// newlines. Trailing empty lines are dropped, so that a string ending with one
+// or more newlines doesn't produce empty items and an empty string produces no
+// lines at all. It is used by the splitLines and line functions and for the
+// output of variables with "split: true".
+func SplitLines(s string) []string {
+	lines := strings.Split(strings.ReplaceAll(s, "\r\n", "\n"), "\n")
+	for len(lines) > 0 && lines[len(lines)-1] == "" {
+		lines = lines[:len(lines)-1]
+	}
+	return lines
+}
+
+// line returns the line of s at index n, starting from 0, like splitLines
+// followed by index does, but failing with a clearer error when there is no
+// such line.
+func line(n int, s string) (string, error) {
+	lines := SplitLines(s)
+	if n < 0 || n >= len(lines) {
+		return "", fmt.Errorf("line %d is out of range; the string has %d lines", n, len(lines))
+	}
+	return lines[n], nil
+}
+
+// psQuote quotes a string to make it a single argument of a PowerShell
+// command. The string is put in single quotes, in which PowerShell doesn't
+// expand anything, and single quotes inside it, including typographic ones
+// which PowerShell treats the same way, are doubled.
+func psQuote(s string) string {
+	var b strings.Builder
+	b.WriteByte('\'')
+	for _, r := range s {
+		switch r {
+		case '\'', '\u2018', '\u2019', '\u201A', '\u201B':
+			b.WriteRune(r)
+		}
+		b.WriteRune(r)
+	}
+	b.WriteByte('\'')
+	return b.String()
+}
+
+// toLF converts the line endings of s to "\n", whether they are "\r\n" or "\r".
+func toLF(s string) string {
+	s = strings.ReplaceAll(s, "\r\n", "\n")
+	return strings.ReplaceAll(s, "\r", "\n")
+}
+
+// toCRLF converts the line endings of s to "\r\n".
+func toCRLF(s string) string {
+	return strings.ReplaceAll(toLF(s), "\n", "\r\n")
+}
+
+// osNewline converts the line endings of s to the ones of the current
+// operating system: "\r\n" on Windows and "\n" on others.
+func osNewline(s string) string {
+	if runtime.GOOS == "windows" {
+		return toCRLF(s)
+	}
+	return toLF(s)
+}
+
+// indentVar indents each line of the value of a variable by the given number
+// of spaces, like indent, but with the value of a list (e.g. a dynamic
+// variable with split) printed one item per line. Line endings are converted
+// to "\n" and trailing newlines, like the ones of a dynamic variable with trim
+// set to none, are dropped. Empty lines are not indented, so no trailing
+// whitespace is added.
+func indentVar(spaces int, v any) string {
+	var s string
+	switch v := v.(type) {
+	case nil:
+	case string:
+		s = v
+	case []string:
+		s = strings.Join(v, "\n")
+	case []any:
+		lines := make([]string, len(v))
+		for i, item := range v {
+			lines[i] = fmt.Sprint(item)
+		}
+		s = strings.Join(lines, "\n")
+	default:
+		s = fmt.Sprint(v)
+	}
+	pad := strings.Repeat(" ", spaces)
+	lines := strings.Split(strings.TrimRight(toLF(s), "\n"), "\n")
+	for i, line := range lines {
+		if line != "" {
+			lines[i] = pad + line
+		}
+	}
+	return strings.Join(lines, "\n")
+}
+
+// expandPath replaces a leading "~" in path with the home directory of the
+// current user. Other paths, including "~user" paths, are returned as is.
+func expandPath(path string) (string, error) {
+	if path != "~" && !strings.HasPrefix(path, "~/") && !strings.HasPrefix(path, "~"+string(filepath.Separator)) {
+		return path, nil
+	}
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(home, path[1:]), nil
+}
+
+// osPathJoin joins a list of paths with the path list separator of the current
+// operating system, e.g. to build a PATH value.
+func osPathJoin(list any) (string, error) {
+	var elems []string
+	switch list := list.(type) {
+	case []string:
+		elems = list
+	case []any:
+		elems = make([]string, len(list))
+		for i, v := range list {
+			elems[i] = fmt.Sprint(v)
+		}
+	default:
+		return "", fmt.Errorf("expected a list of paths; got %T", list)
+	}
+	return strings.Join(elems, string(os.PathListSeparator)), nil
+}
+
+// firstNonEmpty returns the value of the first of the named variables that is
+// set and not empty, or an empty string. Functions can't access the variables
+// of the template, so they are given as the first argument, usually ".".
+// Values are considered empty like sprig's empty function does.
+func firstNonEmpty(vars map[string]any, names ...string) any {
+	for _, name := range names {
+		if v, ok := vars[name]; ok && !isEmpty(v) {
+			return v
+		}
+	}
+	return ""
+}
+
+// isEmpty reports whether v is nil, a zero value, or an empty string, slice
+// or map.
+func isEmpty(v any) bool {
+	if v == nil {
+		return true
+	}
+	rv := reflect.ValueOf(v)
+	switch rv.Kind() {
+	case reflect.Array, reflect.Map, reflect.Slice, reflect.String:
+		return rv.Len() == 0
+	default:
+		return rv.IsZero()
+	}
+}
+
+// mustAtoi converts a value, usually a string variable, to an int. Unlike
+// sprig's atoi and int, it fails instead of returning 0 when the value is not
+// a number. Leading and trailing whitespace is ignored.
+func mustAtoi(v any) (int, error) {
+	s := strings.TrimSpace(fmt.Sprint(v))
+	i, err := strconv.Atoi(s)
+	if err != nil {
+		return 0, fmt.Errorf("%q is not a valid int", s)
+	}
+	return i, nil
+}
+
+// mustAtof is like mustAtoi, but converts the value to a float64.
+func mustAtof(v any) (float64, error) {
+	s := strings.TrimSpace(fmt.Sprint(v))
+	f, err := strconv.ParseFloat(s, 64)
+	if err != nil {
+		return 0, fmt.Errorf("%q is not a valid float", s)
+	}
+	return f, nil
+}
+
+// RunTimeFuncs returns the functions giving the time a run started. Unlike
+// now, they return the same time every time they are called, so all the
+// templates of a run see the same timestamp.
+func RunTimeFuncs(t time.Time) template.FuncMap {
+	return template.FuncMap{
+		"runTime":       func() time.Time { return t },
+		"runTimeFormat": t.Format,
+		"runTimeUTC":    func(layout string) string { return t.UTC().Format(layout) },
+		"runTimeUnix":   t.Unix,
+	}
+}
+
+// fileFuncs returns the functions that work with files. Relative paths are
+// resolved from the given directory, or the current working directory when it
+// is empty.
+func fileFuncs(dir string) template.FuncMap {
+	return template.FuncMap{
+		"readFile": func(path string) (string, error) {
+			b, err := os.ReadFile(filepathext.SmartJoin(dir, path))
+			if err != nil {
+				return "", err
+			}
+			return string(b), nil
+		},
+		"fileChecksum": func(path string) (string, error) {
+			f, err := os.Open(filepathext.SmartJoin(dir, path))
+			if err != nil {
+				return "", err
+			}
+			defer f.Close()
+			h := sha256.New()
+			if _, err := io.Copy(h, f); err != nil {
+				return "", err
+			}
+			return hex.EncodeToString(h.Sum(nil)), nil
+		},
+		"filesChecksum": func(paths ...any) (string, error) {
+			return filesChecksum(dir, paths)
+		},
+		// fileExists follows symlinks, so a broken symlink doesn't exist.
+		"fileExists": func(path string) bool {
+			_, err := os.Stat(filepathext.SmartJoin(dir, path))
+			return err == nil
+		},
+	}
+}
+
+// filesChecksum returns the hex encoded SHA-256 checksum of the paths and
+// contents of the files matching the given globs, each given as a string or
+// as a list of strings (e.g. a glob variable). Relative globs are resolved
+// from dir. The files are sorted and counted once, so the checksum doesn't
+// depend on the order of the globs. A glob matching no file is ignored, but a
+// path without any glob pattern must exist.
+func filesChecksum(dir string, paths []any) (string, error) {
+	var globs []string
+	for _, p := range paths {
+		switch p := p.(type) {
+		case string:
+			globs = append(globs, p)
+		case []string:
+			globs = append(globs, p...)
+		case []any:
+			for _, item := range p {
+				s, ok := item.(string)
+				if !ok {
+					return "", fmt.Errorf("%v is not a path", item)
+				}
+				globs = append(globs, s)
+			}
+		default:
+			return "", fmt.Errorf("%v is not a path or a list of paths", p)
+		}
+	}
+
+	var files []string
+	for _, g := range globs {
+		if !strings.ContainsAny(g, "*?[{") {
+			info, err := os.Stat(filepathext.SmartJoin(dir, g))
+			if err != nil {
+				return "", err
+			}
+			if info.IsDir() {
+				return "", fmt.Errorf("%s is a directory", g)
+			}
+		}
+		matches, err := fingerprint.Glob(dir, g)
+		if err != nil && !errors.Is(err, os.ErrNotExist) {
+			return "", err
+		}
+		for _, match := range matches {
+			if !slices.Contains(files, match) {
+				files = append(files, match)
+			}
+		}
+	}
+	slices.Sort(files)
+
+	h := sha256.New()
+	for _, file := range files {
+		// Paths are part of the checksum, so renaming a file changes it
+		rel, err := filepath.Rel(cmp.Or(dir, "."), file)
+		if err != nil {
+			rel = file
+		}
+		b, err := os.ReadFile(file)
+		if err != nil {
+			return "", err
+		}
+		fmt.Fprintf(h, "%s\x00%x\n", filepath.ToSlash(rel), sha256.Sum256(b))
+	}
+	return hex.EncodeToString(h.Sum(nil)), nil
 }
@@ -0,0 +1,242 @@
+package templater_test
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/go-task/task/v3/internal/templater"
+	"github.com/go-task/task/v3/taskfile/ast"
+)
+
+func TestFuncs(t *testing.T) {
+	t.Setenv("TASK_TEST_FUNCS_ENV", "from-env")
+
+	hostname, err := os.Hostname()
+	require.NoError(t, err)
+	home, err := os.UserHomeDir()
+	require.NoError(t, err)
+
+	vars := &ast.Vars{}
+	vars.Set("JSON", ast.Var{Value: `{"tag_name": "v1.0.0", "assets": [1, 2]}`})
+	vars.Set("INVALID_JSON", ast.Var{Value: `{"tag_name"`})
+	vars.Set("MAP", ast.Var{Value: map[string]any{"env": "prod", "tier": map[string]any{"name": "web"}}})
+	vars.Set("YAML", ast.Var{Value: "env: prod\nports: [80, 443]\n"})
+	vars.Set("INVALID_YAML", ast.Var{Value: "env: [prod"})
+	vars.Set("TASK_TEST_FUNCS_ENV", ast.Var{Value: "from-vars"})
+	vars.Set("QUOTES", ast.Var{Value: `it's a "test" of $HOME`})
+	vars.Set("BUILD", ast.Var{Value: "41"})
+	vars.Set("RATIO", ast.Var{Value: "1.5"})
+	vars.Set("LOG", ast.Var{Value: "abc123 Fix tests\r\ndef456 Add docs\n012abc Initial commit"})
+	vars.Set("EMPTY", ast.Var{Value: ""})
+	vars.Set("EMPTY_LIST", ast.Var{Value: []any{}})
+	vars.Set("LIST", ast.Var{Value: []any{"a", 1}})
+
+	tests := []struct {
+		name        string
+		template    string
+		expected    string
+		expectedErr string
+	}{
+		{
+			name:     "fromJson",
+			template: `{{(.JSON | fromJson).tag_name}} {{len (.JSON | fromJson).assets}}`,
+			expected: "v1.0.0 2",
+		},
+		{
+			name:        "fromJson with invalid JSON",
+			template:    `{{.INVALID_JSON | fromJson}}`,
+			expectedErr: "error calling fromJson: unexpected end of JSON input",
+		},
+		{
+			name:     "toYaml",
+			template: `{{.MAP | toYaml}}`,
+			expected: "env: prod\ntier:\n  name: web",
+		},
+		{
+			name:     "fromYaml",
+			template: `{{(.YAML | fromYaml).env}} {{index (.YAML | fromYaml).ports 1}}`,
+			expected: "prod 443",
+		},
+		{
+			name:        "fromYaml with invalid YAML",
+			template:    `{{.INVALID_YAML | fromYaml}}`,
+			expectedErr: "error calling fromYaml: yaml: line 1: did not find expected ',' or ']'",
+		},
+		{
+			name:     "env",
+			template: `{{.TASK_TEST_FUNCS_ENV}} {{env "TASK_TEST_FUNCS_ENV"}}`,
+			expected: "from-vars from-env",
+		},
+		{
+			name:     "envDefault",
+			template: `{{envDefault "TASK_TEST_FUNCS_ENV" "fallback"}} {{envDefault "TASK_TEST_FUNCS_UNSET" "fallback"}}`,
+			expected: "from-env fallback",
+		},
+		{
+			name:     "hostname",
+			template: `{{hostname}}`,
+			expected: hostname,
+		},
+		{
+			name:     "userHomeDir",
+			template: `{{userHomeDir}}`,
+			expected: home,
+		},
+		{
+			name:     "expandPath",
+			template: `{{expandPath "~"}} {{expandPath "~/bin"}} {{expandPath "/opt/~/bin"}} {{expandPath "~user/bin"}}`,
+			expected: home + " " + filepath.Join(home, "bin") + " /opt/~/bin ~user/bin",
+		},
+		{
+			name:     "osPathJoin",
+			template: `{{osPathJoin (list "/usr/bin" "/opt/bin")}} {{splitList "," "a,b" | osPathJoin}}`,
+			expected: "/usr/bin" + string(os.PathListSeparator) + "/opt/bin a" + string(os.PathListSeparator) + "b",
+		},
+		{
+			name:        "osPathJoin with a string",
+			template:    `{{osPathJoin "/usr/bin"}}`,
+			expectedErr: "error calling osPathJoin: expected a list of paths; got string",
+		},
+		{
+			name:     "mustAtoi",
+			template: `{{add (mustAtoi .BUILD) 1}} {{mustAtoi " 7\n"}} {{mustAtoi 3}}`,
+			expected: "42 7 3",
+		},
+		{
+			name:        "mustAtoi with a non-numeric value",
+			template:    `{{mustAtoi "v1"}}`,
+			expectedErr: `error calling mustAtoi: "v1" is not a valid int`,
+		},
+		{
+			name:     "mustAtof",
+			template: `{{maxf (mustAtof .RATIO) 1}} {{mustAtof "3"}}`,
+			expected: "1.5 3",
+		},
+		{
+			name:        "mustAtof with a non-numeric value",
+			template:    `{{mustAtof "1.5x"}}`,
+			expectedErr: `error calling mustAtof: "1.5x" is not a valid float`,
+		},
+		{
+			name:     "firstNonEmpty",
+			template: `{{firstNonEmpty . "MISSING" "EMPTY" "EMPTY_LIST" "BUILD" "RATIO"}} {{firstNonEmpty . "MISSING" "EMPTY" | default "fallback"}}`,
+			expected: "41 fallback",
+		},
+		{
+			name:     "line",
+			template: `{{line 0 .LOG}}|{{.LOG | line 1}}|{{index (splitLines .LOG) 2}}`,
+			expected: "abc123 Fix tests|def456 Add docs|012abc Initial commit",
+		},
+		{
+			name:     "splitLines drops trailing empty lines",
+			template: `{{splitLines "a\r\n\nb\n\n" | toJson}} {{splitLines "" | len}} {{line 1 "a\r\nb\r\n"}}`,
+			expected: `["a","","b"] 0 b`,
+		},
+		{
+			name:     "toLF",
+			template: `{{.LOG | toLF | quote}} {{"a\rb" | toLF | quote}}`,
+			expected: `"abc123 Fix tests\ndef456 Add docs\n012abc Initial commit" "a\nb"`,
+		},
+		{
+			name:     "indentVar",
+			template: `{{indentVar 2 "a\r\n\n  b\n\n" | quote}} {{.LIST | indentVar 4 | quote}} {{.EMPTY | indentVar 2 | quote}}`,
+			expected: `"  a\n\n    b" "    a\n    1" ""`,
+		},
+		{
+			name:     "nindentVar",
+			template: `key:{{.LOG | nindentVar 2}}`,
+			expected: "key:\n  abc123 Fix tests\n  def456 Add docs\n  012abc Initial commit",
+		},
+		{
+			name:     "toCRLF",
+			template: `{{.LOG | toCRLF | quote}}`,
+			expected: `"abc123 Fix tests\r\ndef456 Add docs\r\n012abc Initial commit"`,
+		},
+		{
+			name:     "osNewline",
+			template: `{{eq (osNewline .LOG) (ternary (toCRLF .LOG) (toLF .LOG) (eq OS "windows"))}}`,
+			expected: "true",
+		},
+		{
+			name:        "line out of range",
+			template:    `{{line 3 .LOG}}`,
+			expectedErr: "error calling line: line 3 is out of range; the string has 3 lines",
+		},
+		{
+			name:     "string functions",
+			template: `{{"v1.2.3" | strTrimPrefix "v"}} {{strTrimSuffix ".go" "main.go"}} {{strReplace "-" "_" "a-b-c"}} {{strUpper "a"}}{{strLower "B"}} {{strRepeat 2 "ab"}} [{{strTrim " x "}}] {{strContains "b" "abc"}} {{strHasPrefix "a" "abc"}} {{strHasSuffix "a" "abc"}}`,
+			expected: "1.2.3 main a_b_c Ab abab [x] true true false",
+		},
+		{
+			name:     "shellQuote",
+			template: `{{shellQuote "a b"}} {{q "it's"}} {{.QUOTES | shellQuote}} {{shellQuote ""}}`,
+			expected: `'a b' "it's" "it's a \"test\" of \$HOME" ''`,
+		},
+		{
+			name:     "shQuote",
+			template: `{{shQuote "a b"}} {{.QUOTES | shQuote}} {{shQuote ""}}`,
+			expected: `'a b' "it's a \"test\" of \$HOME" ''`,
+		},
+		{
+			name:        "shQuote with a control character",
+			template:    `{{shQuote "a\tb"}}`,
+			expectedErr: "error calling shQuote",
+		},
+		{
+			name:     "url escaping",
+			template: `{{urlQueryEscape "a b&c/d"}} {{urlPathEscape "a b&c/d"}} {{urlQueryUnescape "a+b%26c"}} {{urlPathUnescape "a+b%20c"}}`,
+			expected: "a+b%26c%2Fd a%20b&c%2Fd a b&c a+b c",
+		},
+		{
+			name:        "url unescaping an invalid value",
+			template:    `{{urlQueryUnescape "100%"}}`,
+			expectedErr: `error calling urlQueryUnescape: invalid URL escape "%"`,
+		},
+		{
+			name:     "psQuote",
+			template: `{{psQuote "a b"}} {{.QUOTES | psQuote}} {{psQuote "it\u2019s"}} {{psQuote ""}}`,
+			expected: "'a b' 'it''s a \"test\" of $HOME' 'it\u2019\u2019s' ''",
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			cache := &templater.Cache{Vars: vars}
+			result := templater.Replace(test.template, cache)
+			if test.expectedErr != "" {
+				require.ErrorContains(t, cache.Err(), test.expectedErr)
+				return
+			}
+			require.NoError(t, cache.Err())
+			assert.Equal(t, test.expected, result)
+		})
+	}
+}
+
+func TestRunTimeFuncs(t *testing.T) {
+	t.Parallel()
+
+	runTime := time.Date(2024, time.March, 1, 23, 30, 0, 0, time.FixedZone("", -2*60*60))
+	cache := &templater.Cache{Funcs: templater.RunTimeFuncs(runTime)}
+	result := templater.Replace(`{{runTimeFormat "2006-01-02 15:04"}} {{runTimeUTC "2006-01-02 15:04"}} {{runTimeUnix}} {{runTime.Year}}`, cache)
+	require.NoError(t, cache.Err())
+	assert.Equal(t, "2024-03-01 23:30 2024-03-02 01:30 1709343000 2024", result)
+}
+
+func TestSafeFuncs(t *testing.T) {
+	t.Parallel()
+
+	funcs := templater.NewSafeFuncs()
+	assert.Len(t, funcs, len(templater.SafeFuncNames))
+	for _, name := range templater.SafeFuncNames {
+		assert.NotNil(t, funcs[name], name)
+	}
+	for _, name := range []string{"env", "expandenv", "envDefault", "readFile", "fileExists", "fileChecksum", "filesChecksum", "hostname", "userHomeDir", "expandPath", "now", "runTime", "randInt", "sha256sum", "getHostByName"} {
+		assert.NotContains(t, funcs, name)
+	}
+}
@@ -2,10 +2,15 @@ package templater
 
 import (
 	"bytes"
+	"cmp"
 	"fmt"
 	"maps"
+	"regexp"
+	"slices"
+	"strconv"
 	"strings"
 
+	"github.com/go-task/task/v3/errors"
 	"github.com/go-task/task/v3/internal/deepcopy"
 	"github.com/go-task/task/v3/taskfile/ast"
 	"github.com/go-task/template"
@@ -17,6 +22,15 @@ import (
 // return the zero value.
 type Cache struct {
 	Vars *ast.Vars
+	// Delims are the action delimiters used when parsing templates. The
+	// default delimiters are used when unset.
+	Delims ast.Delims
+	// Funcs are the functions available to templates. The default functions
+	// are used when nil.
+	Funcs template.FuncMap
+	// Strict makes referencing a variable that is not set an error instead of
+	// rendering an empty string.
+	Strict bool
 
 	cacheMap map[string]any
 	err      error
@@ -30,6 +44,14 @@ func (r *Cache) Err() error {
 	return r.err
 }
 
+func (r *Cache) parse(source string) (*template.Template, error) {
+	funcs := r.Funcs
+	if funcs == nil {
+		funcs = templateFuncs
+	}
+	return parsedTemplates.parse(source, r.Delims, funcs, r.Strict)
+}
+
 func ResolveRef(ref string, cache *Cache) any {
 	// If there is already an error, do nothing
 	if cache.err != nil {
@@ -44,14 +66,16 @@ func ResolveRef(ref string, cache *Cache) any {
 	if ref == "." {
 		return cache.cacheMap
 	}
-	t, err := template.New("resolver").Funcs(templateFuncs).Parse(fmt.Sprintf("{{%s}}", ref))
+	left, right := cmp.Or(cache.Delims.Left, "{{"), cmp.Or(cache.Delims.Right, "}}")
+	source := left + ref + right
+	t, err := cache.parse(source)
 	if err != nil {
-		cache.err = err
+		cache.err = newTemplateError(source, err)
 		return nil
 	}
 	val, err := t.Resolve(cache.cacheMap)
 	if err != nil {
-		cache.err = err
+		cache.err = newTemplateError(source, err)
 		return nil
 	}
 	return val
@@ -81,13 +105,13 @@ func ReplaceWithExtra[T any](v T, cache *Cache, extra map[string]any) T {
 
 	// Traverse the value and parse any template variables
 	copy, err := deepcopy.TraverseStringsFunc(v, func(v string) (string, error) {
-		tpl, err := template.New("").Funcs(templateFuncs).Parse(v)
+		tpl, err := cache.parse(v)
 		if err != nil {
-			return v, err
+			return v, newTemplateError(v, err)
 		}
 		var b bytes.Buffer
 		if err := tpl.Execute(&b, data); err != nil {
-			return v, err
+			return v, newTemplateError(v, err)
 		}
 		return strings.ReplaceAll(b.String(), "<no value>", ""), nil
 	})
@@ -99,6 +123,73 @@ func ReplaceWithExtra[T any](v T, cache *Cache, extra map[string]any) T {
 	return copy
 }
 
+// ParseVar parses the templates of v without rendering them, using the
+// delimiters and functions of cache, and returns the error of the first one
+// that is invalid.
+func ParseVar(v ast.Var, cache *Cache) error {
+	parse := func(source string) (string, error) {
+		if _, err := cache.parse(source); err != nil {
+			return source, newTemplateError(source, err)
+		}
+		return source, nil
+	}
+	if v.Ref != "" {
+		left, right := cmp.Or(cache.Delims.Left, "{{"), cmp.Or(cache.Delims.Right, "}}")
+		_, err := parse(left + v.Ref + right)
+		return err
+	}
+	for _, value := range []any{v.Value, v.Sh, v.File, v.Glob, v.Dir, v.Stdin} {
+		if _, err := deepcopy.TraverseStringsFunc(value, parse); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// ReplaceMap replaces the templates in the values of m.
+func ReplaceMap(m map[string]string, cache *Cache) map[string]string {
+	return replaceMap(m, cache, false)
+}
+
+// ReplaceMapKeys replaces the templates in both the keys and the values of m.
+// It is an error for two keys to be replaced by the same key.
+func ReplaceMapKeys(m map[string]string, cache *Cache) map[string]string {
+	return replaceMap(m, cache, true)
+}
+
+func replaceMap(m map[string]string, cache *Cache, replaceKeys bool) map[string]string {
+	if cache.err != nil || m == nil {
+		return nil
+	}
+
+	// Replace the keys in order, so the same error is reported every time
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	slices.Sort(keys)
+
+	new := make(map[string]string, len(m))
+	origKeys := make(map[string]string, len(m))
+	for _, k := range keys {
+		newKey := k
+		if replaceKeys {
+			newKey = Replace(k, cache)
+		}
+		newValue := Replace(m[k], cache)
+		if cache.err != nil {
+			return nil
+		}
+		if origKey, ok := origKeys[newKey]; ok {
+			cache.err = fmt.Errorf(`task: Map keys "%s" and "%s" are both replaced by "%s"`, origKey, k, newKey)
+			return nil
+		}
+		origKeys[newKey] = k
+		new[newKey] = newValue
+	}
+	return new
+}
+
 func ReplaceGlobs(globs []*ast.Glob, cache *Cache) []*ast.Glob {
 	if cache.err != nil || len(globs) == 0 {
 		return nil
@@ -120,14 +211,35 @@ func ReplaceVar(v ast.Var, cache *Cache) ast.Var {
 
 func ReplaceVarWithExtra(v ast.Var, cache *Cache, extra map[string]any) ast.Var {
 	if v.Ref != "" {
-		return ast.Var{Value: ResolveRef(v.Ref, cache)}
+		return ast.Var{Value: ResolveRef(v.Ref, cache), Secret: v.Secret, Merge: v.Merge}
 	}
 	return ast.Var{
-		Value: ReplaceWithExtra(v.Value, cache, extra),
-		Sh:    ReplaceWithExtra(v.Sh, cache, extra),
-		Live:  v.Live,
-		Ref:   v.Ref,
-		Dir:   v.Dir,
+		Value:   ReplaceWithExtra(v.Value, cache, extra),
+		Sh:      ReplaceWithExtra(v.Sh, cache, extra),
+		Live:    v.Live,
+		Ref:     v.Ref,
+		File:    ReplaceWithExtra(v.File, cache, extra),
+		Glob:    ReplaceWithExtra(v.Glob, cache, extra),
+		Dir:     ReplaceWithExtra(v.Dir, cache, extra),
+		Split:   v.Split,
+		Trim:    v.Trim,
+		Encode:  v.Encode,
+		Timeout: v.Timeout,
+		Shell:   v.Shell,
+		Persist: v.Persist,
+		TTL:     v.TTL,
+		Type:    v.Type,
+		Secret:  v.Secret,
+		Stderr:  v.Stderr,
+		Stdin:   ReplaceWithExtra(v.Stdin, cache, extra),
+		Lazy:    v.Lazy,
+		Merge:   v.Merge,
+		Export:  v.Export,
+
+		Retries:    v.Retries,
+		RetryDelay: v.RetryDelay,
+		NonEmpty:   v.NonEmpty,
+		Optional:   v.Optional,
 	}
 }
 
@@ -143,8 +255,43 @@ func ReplaceVarsWithExtra(vars *ast.Vars, cache *Cache, extra map[string]any) *a
 	var newVars ast.Vars
 	_ = vars.Range(func(k string, v ast.Var) error {
 		newVars.Set(k, ReplaceVarWithExtra(v, cache, extra))
+		SetErrVar(cache.err, k)
 		return nil
 	})
 
 	return &newVars
 }
+
+// templateErrorRegex matches the errors of the template package, which are
+// prefixed by the name of the template and the location of the error (e.g.
+// `template: :1:7: executing "" at <.FOO>: ...` or `template: :1: ...`).
+var templateErrorRegex = regexp.MustCompile(`(?s)^template: [^:]*:(\d+)(?::(\d+))?: (?:executing "[^"]*" )?(.*)$`)
+
+// newTemplateError returns a TemplateError for an error that happened while
+// parsing or executing source.
+func newTemplateError(source string, err error) error {
+	templateErr := &errors.TemplateError{
+		Template: source,
+		Message:  err.Error(),
+		Err:      err,
+	}
+	if m := templateErrorRegex.FindStringSubmatch(err.Error()); m != nil {
+		templateErr.Line, _ = strconv.Atoi(m[1])
+		if m[2] != "" {
+			column, _ := strconv.Atoi(m[2])
+			templateErr.Column = column + 1
+		}
+		templateErr.Message = m[3]
+	}
+	return templateErr
+}
+
+// SetErrVar records that err happened while replacing the variable with the
+// given name, if err is a template error that isn't attributed to a variable
+// yet.
+func SetErrVar(err error, name string) {
+	var templateErr *errors.TemplateError
+	if errors.As(err, &templateErr) && templateErr.Var == "" {
+		templateErr.Var = name
+	}
+}
@@ -0,0 +1,130 @@
+package templater_test
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/go-task/task/v3/errors"
+	"github.com/go-task/task/v3/internal/templater"
+	"github.com/go-task/task/v3/taskfile/ast"
+)
+
+func TestReplaceErrors(t *testing.T) {
+	t.Parallel()
+
+	vars := &ast.Vars{}
+	vars.Set("JSON", ast.Var{Value: `{"tag_name"`})
+
+	tests := []struct {
+		name     string
+		vars     *ast.Vars
+		expected string
+	}{
+		{
+			name: "parse error",
+			vars: newVars("FOO", ast.Var{Value: "echo {{.JSON}"}),
+			expected: `task: Failed to render the template of variable "FOO": bad character U+007D '}'
+  1 | echo {{.JSON}`,
+		},
+		{
+			name: "execution error",
+			vars: newVars("FOO", ast.Var{Value: "first line\necho {{fromJson .JSON}}"}),
+			expected: `task: Failed to render the template of variable "FOO": at <fromJson .JSON>: error calling fromJson: unexpected end of JSON input
+  2 | echo {{fromJson .JSON}}
+    |        ^`,
+		},
+		{
+			name: "dynamic variable",
+			vars: newVars("BAR", ast.Var{Sh: ptr("echo {{.JSON | bad}}")}),
+			expected: `task: Failed to render the template of variable "BAR": function "bad" not defined
+  1 | echo {{.JSON | bad}}`,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			t.Parallel()
+
+			cache := &templater.Cache{Vars: vars}
+			templater.ReplaceVars(test.vars, cache)
+			var templateErr *errors.TemplateError
+			require.ErrorAs(t, cache.Err(), &templateErr)
+			assert.Equal(t, test.expected, cache.Err().Error())
+		})
+	}
+}
+
+func TestReplaceMap(t *testing.T) {
+	t.Parallel()
+
+	vars := &ast.Vars{}
+	vars.Set("NAME", ast.Var{Value: "foo"})
+	vars.Set("OTHER", ast.Var{Value: "FOO"})
+
+	tests := []struct {
+		name        string
+		m           map[string]string
+		replaceKeys bool
+		expected    map[string]string
+		expectedErr string
+	}{
+		{
+			name:     "values",
+			m:        map[string]string{"{{.NAME}}": "{{.NAME}}-value", "PLAIN": "plain"},
+			expected: map[string]string{"{{.NAME}}": "foo-value", "PLAIN": "plain"},
+		},
+		{
+			name:        "keys and values",
+			m:           map[string]string{"{{.NAME}}": "{{.NAME}}-value", "PLAIN": "plain"},
+			replaceKeys: true,
+			expected:    map[string]string{"foo": "foo-value", "PLAIN": "plain"},
+		},
+		{
+			name:        "duplicate keys",
+			m:           map[string]string{"{{.OTHER}}": "a", "FOO": "b"},
+			replaceKeys: true,
+			expectedErr: `task: Map keys "FOO" and "{{.OTHER}}" are both replaced by "FOO"`,
+		},
+		{
+			name:        "invalid value",
+			m:           map[string]string{"A": "{{.NAME", "B": "b"},
+			expectedErr: `task: Failed to render template: unclosed action`,
+		},
+		{
+			name:     "nil",
+			expected: nil,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			t.Parallel()
+
+			cache := &templater.Cache{Vars: vars}
+			replace := templater.ReplaceMap
+			if test.replaceKeys {
+				replace = templater.ReplaceMapKeys
+			}
+			result := replace(test.m, cache)
+			if test.expectedErr != "" {
+				require.ErrorContains(t, cache.Err(), test.expectedErr)
+				assert.Nil(t, result)
+				return
+			}
+			require.NoError(t, cache.Err())
+			assert.Equal(t, test.expected, result)
+		})
+	}
+}
+
+func newVars(name string, v ast.Var) *ast.Vars {
+	vars := &ast.Vars{}
+	vars.Set(name, v)
+	return vars
+}
+
+func ptr[T any](v T) *T {
+	return &v
+}
@@ -0,0 +1,92 @@
+package templater
+
+import (
+	"container/list"
+	"reflect"
+	"sync"
+
+	"github.com/go-task/task/v3/taskfile/ast"
+	"github.com/go-task/template"
+)
+
+// maxParsedTemplates is the number of parsed templates kept in memory.
+const maxParsedTemplates = 4096
+
+// parsedTemplates caches parsed templates, so the same template is not parsed
+// again every time it is rendered. Parsed templates are safe to execute
+// concurrently.
+var parsedTemplates = newTemplateCache(maxParsedTemplates)
+
+// templateKey identifies a parsed template. The functions are identified by
+// the address of their map, which can't be reused by another map while a
+// cached template keeps a reference to it.
+type templateKey struct {
+	source string
+	delims ast.Delims
+	funcs  uintptr
+	strict bool
+}
+
+type templateEntry struct {
+	key   templateKey
+	tpl   *template.Template
+	funcs template.FuncMap
+}
+
+// templateCache is a least recently used cache of parsed templates.
+type templateCache struct {
+	mu      sync.Mutex
+	size    int
+	order   *list.List
+	entries map[templateKey]*list.Element
+}
+
+func newTemplateCache(size int) *templateCache {
+	return &templateCache{
+		size:    size,
+		order:   list.New(),
+		entries: make(map[templateKey]*list.Element, size),
+	}
+}
+
+// parse returns source parsed with the given delimiters and functions. Strict
+// templates fail to execute when they reference a missing map key.
+func (c *templateCache) parse(source string, delims ast.Delims, funcs template.FuncMap, strict bool) (*template.Template, error) {
+	key := templateKey{
+		source: source,
+		delims: delims,
+		funcs:  reflect.ValueOf(funcs).Pointer(),
+		strict: strict,
+	}
+
+	c.mu.Lock()
+	if elem, ok := c.entries[key]; ok {
+		c.order.MoveToFront(elem)
+		c.mu.Unlock()
+		return elem.Value.(*templateEntry).tpl, nil
+	}
+	c.mu.Unlock()
+
+	tpl := template.New("").Delims(delims.Left, delims.Right).Funcs(funcs)
+	if strict {
+		tpl = tpl.Option("missingkey=error")
+	}
+	tpl, err := tpl.Parse(source)
+	if err != nil {
+		return nil, err
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if elem, ok := c.entries[key]; ok {
+		c.order.MoveToFront(elem)
+		return elem.Value.(*templateEntry).tpl, nil
+	}
+	c.entries[key] = c.order.PushFront(&templateEntry{key: key, tpl: tpl, funcs: funcs})
+	if c.order.Len() > c.size {
+		oldest := c.order.Back()
+		c.order.Remove(oldest)
+		delete(c.entries, oldest.Value.(*templateEntry).key)
+	}
+	return tpl, nil
+}
@@ -0,0 +1,44 @@
+package templater
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/go-task/task/v3/taskfile/ast"
+)
+
+func TestTemplateCache(t *testing.T) {
+	t.Parallel()
+
+	c := newTemplateCache(2)
+	funcs := NewFuncs("")
+
+	first, err := c.parse("{{.FOO}}", ast.Delims{}, funcs, false)
+	require.NoError(t, err)
+	second, err := c.parse("{{.FOO}}", ast.Delims{}, funcs, false)
+	require.NoError(t, err)
+	assert.Same(t, first, second)
+
+	other, err := c.parse("{{.FOO}}", ast.Delims{}, NewFuncs("dir"), false)
+	require.NoError(t, err)
+	assert.NotSame(t, first, other)
+	delims, err := c.parse("{{.FOO}}", ast.Delims{Left: "[[", Right: "]]"}, funcs, false)
+	require.NoError(t, err)
+	assert.NotSame(t, first, delims)
+
+	_, err = c.parse("{{.FOO", ast.Delims{}, funcs, false)
+	require.Error(t, err)
+
+	for i := range 3 {
+		_, err := c.parse(fmt.Sprintf("{{.VAR%d}}", i), ast.Delims{}, funcs, false)
+		require.NoError(t, err)
+	}
+	assert.Equal(t, 2, c.order.Len())
+	assert.Len(t, c.entries, 2)
+	again, err := c.parse("{{.FOO}}", ast.Delims{}, funcs, false)
+	require.NoError(t, err)
+	assert.NotSame(t, first, again)
+}
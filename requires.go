@@ -1,13 +1,14 @@
 package task
 
 import (
+	"context"
 	"slices"
 
 	"github.com/go-task/task/v3/errors"
 	"github.com/go-task/task/v3/taskfile/ast"
 )
 
-func (e *Executor) areTaskRequiredVarsSet(t *ast.Task, call *ast.Call) error {
+func (e *Executor) areTaskRequiredVarsSet(ctx context.Context, t *ast.Task, call *ast.Call) error {
 	if t.Requires == nil || len(t.Requires.Vars) == 0 {
 		return nil
 	}
@@ -16,12 +17,20 @@ func (e *Executor) areTaskRequiredVarsSet(t *ast.Task, call *ast.Call) error {
 	if err != nil {
 		return err
 	}
+	names := make([]string, len(t.Requires.Vars))
+	for i, requiredVar := range t.Requires.Vars {
+		names[i] = requiredVar.Name
+	}
+	if err := e.Compiler.ResolveLazyVars(ctx, vars, names...); err != nil {
+		return err
+	}
 
 	var missingVars []string
 	var notAllowedValuesVars []errors.NotAllowedVar
 	for _, requiredVar := range t.Requires.Vars {
 		value, isString := vars.Get(requiredVar.Name).Value.(string)
-		if !vars.Exists(requiredVar.Name) {
+		// Variables set to an empty string are considered missing
+		if !vars.Exists(requiredVar.Name) || (isString && value == "") {
 			missingVars = append(missingVars, requiredVar.Name)
 		} else {
 			if isString && requiredVar.Enum != nil && !slices.Contains(requiredVar.Enum, value) {
@@ -3,11 +3,13 @@ package task
 import (
 	"context"
 	"fmt"
+	"maps"
 	"os"
 	"path/filepath"
 	"slices"
 	"strings"
 	"sync"
+	"time"
 
 	"github.com/Masterminds/semver/v3"
 	"github.com/sajari/fuzzy"
@@ -18,12 +20,17 @@ import (
 	"github.com/go-task/task/v3/internal/filepathext"
 	"github.com/go-task/task/v3/internal/logger"
 	"github.com/go-task/task/v3/internal/output"
+	"github.com/go-task/task/v3/internal/templater"
 	"github.com/go-task/task/v3/internal/version"
 	"github.com/go-task/task/v3/taskfile"
 	"github.com/go-task/task/v3/taskfile/ast"
+	"github.com/go-task/template"
 )
 
 func (e *Executor) Setup() error {
+	if e.RunTime.IsZero() {
+		e.RunTime = time.Now()
+	}
 	e.setupLogger()
 	node, err := e.getRootNode()
 	if err != nil {
@@ -35,14 +42,23 @@ func (e *Executor) Setup() error {
 	if err := e.readTaskfile(node); err != nil {
 		return err
 	}
+	if err := e.readTaskvarsFiles(); err != nil {
+		return err
+	}
 	e.setupFuzzyModel()
 	e.setupStdFiles()
 	if err := e.setupOutput(); err != nil {
 		return err
 	}
+	if err := e.checkVarNames(); err != nil {
+		return err
+	}
 	if err := e.setupCompiler(); err != nil {
 		return err
 	}
+	if err := e.LoadDynamicCache(); err != nil {
+		return err
+	}
 	if err := e.readDotEnvFiles(); err != nil {
 		return err
 	}
@@ -72,6 +88,7 @@ func (e *Executor) readTaskfile(node taskfile.Node) error {
 		e.Timeout,
 		e.TempDir.Remote,
 		e.Logger,
+		e.templateFuncs(),
 	)
 	graph, err := reader.Read()
 	if err != nil {
@@ -191,17 +208,93 @@ func (e *Executor) setupCompiler() error {
 		}
 	}
 
+	funcs := e.templateFuncs()
+	funcs["taskVar"] = e.taskVar(nil)
+
+	// Like the variables given on the command line, the arguments override a
+	// CLI_ARGS variable declared in the Taskfile
+	if e.CLIArgs != nil {
+		cliArgs, err := compiler.QuoteArgs(e.CLIArgs)
+		if err != nil {
+			return err
+		}
+		e.Taskfile.Vars.Set("CLI_ARGS", ast.Var{Value: cliArgs})
+	}
+
 	e.Compiler = &compiler.Compiler{
 		Dir:            e.Dir,
 		Entrypoint:     e.Entrypoint,
 		UserWorkingDir: e.UserWorkingDir,
+		RunTime:        e.RunTime,
 		TaskfileEnv:    e.Taskfile.Env,
 		TaskfileVars:   e.Taskfile.Vars,
+		Delims:         e.Taskfile.Delims,
+		Funcs:          funcs,
+		CLIArgs:        e.CLIArgs,
+		EnvAllow:       e.EnvAllow,
+		EnvDeny:        e.EnvDeny,
+		EnvNamespace:   e.EnvNamespace,
 		Logger:         e.Logger,
+
+		DisableDynamicCache:        e.DisableDynamicCache,
+		DynamicCacheTTL:            e.DynamicCacheTTL,
+		DynamicVarProgressInterval: e.DynamicVarProgressInterval,
+		ParallelDynamicVars:        e.ParallelDynamicVars,
+		DryRunVars:                 e.DryRunVars,
+		StrictTemplates:            e.StrictTemplates,
+		VarTransform:               e.VarTransform,
+		DynamicVarPath:             e.DynamicVarPath,
+		OnVarResolved:              e.OnVarResolved,
+		OnVarCacheHit:              e.OnVarCacheHit,
 	}
 	return nil
 }
 
+// AddTemplateFunc makes fn available to the templates of the Taskfile as a
+// function with the given name, like the functions provided by Task itself,
+// which can't be overridden. It must be called before Setup. fn must be a
+// function returning a value and, optionally, an error.
+func (e *Executor) AddTemplateFunc(name string, fn any) error {
+	if _, ok := templater.NewFuncs("")[name]; ok || name == "taskVar" {
+		return fmt.Errorf(`task: Template function "%s" is provided by Task and can't be overridden`, name)
+	}
+	if err := checkTemplateFunc(name, fn); err != nil {
+		return err
+	}
+	if e.customFuncs == nil {
+		e.customFuncs = template.FuncMap{}
+	}
+	e.customFuncs[name] = fn
+	return nil
+}
+
+// checkTemplateFunc returns an error if the template package doesn't accept
+// fn as a function with the given name.
+func checkTemplateFunc(name string, fn any) (err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			err = fmt.Errorf(`task: Invalid template function "%s": %v`, name, r)
+		}
+	}()
+	template.New("").Funcs(template.FuncMap{name: fn})
+	return nil
+}
+
+// templateFuncs returns the functions available to the templates of the
+// Taskfile. Functions added with AddTemplateFunc are available even when only
+// safe functions are.
+func (e *Executor) templateFuncs() template.FuncMap {
+	var funcs template.FuncMap
+	if e.SafeTemplateFuncs {
+		funcs = templater.NewSafeFuncs()
+	} else {
+		funcs = templater.NewFuncs(e.Dir)
+		maps.Copy(funcs, templater.RunTimeFuncs(e.RunTime))
+	}
+	maps.Copy(funcs, e.customFuncs)
+	return funcs
+}
+
 func (e *Executor) readDotEnvFiles() error {
 	if e.Taskfile.Version.LessThan(ast.V3) {
 		return nil
@@ -1,6 +1,7 @@
 package task
 
 import (
+	"cmp"
 	"context"
 	"fmt"
 	"io"
@@ -25,6 +26,7 @@ import (
 	"github.com/go-task/task/v3/internal/summary"
 	"github.com/go-task/task/v3/internal/templater"
 	"github.com/go-task/task/v3/taskfile/ast"
+	"github.com/go-task/template"
 
 	"github.com/sajari/fuzzy"
 	"golang.org/x/sync/errgroup"
@@ -66,6 +68,95 @@ type Executor struct {
 	Concurrency int
 	Interval    time.Duration
 
+	// DisableDynamicCache makes dynamic variables run their command every time
+	// they are resolved instead of reusing the first result.
+	DisableDynamicCache bool
+	// DynamicCacheTTL is how long the results of dynamic variables are cached
+	// for, unless a variable sets its own "ttl". Zero means forever.
+	DynamicCacheTTL time.Duration
+	// DynamicVarProgressInterval is how often, in verbose mode, Task tells that
+	// the command of a dynamic variable is still running. Zero means every 10
+	// seconds, and a negative duration disables these messages.
+	DynamicVarProgressInterval time.Duration
+	// ParallelDynamicVars resolves consecutive dynamic variables that don't
+	// reference each other concurrently instead of one after the other.
+	ParallelDynamicVars bool
+	// DryRunVars resolves dynamic variables to a "<dynamic>" placeholder
+	// instead of running their command. Templates are still rendered, so
+	// their syntax can be validated without side effects.
+	DryRunVars bool
+	// DynamicVarPath are directories added before the directories of PATH
+	// when running the commands of dynamic variables, e.g. to find tools
+	// installed in the project. Earlier directories are searched first.
+	// Relative paths are resolved from the directory of the root Taskfile.
+	// The environment of Task itself is not changed.
+	DynamicVarPath []string
+	// OnVarResolved, when set, is called every time the command of a dynamic
+	// variable is run successfully, with the time it took, including retries.
+	// It is not called when the cached result is used. Secrets are redacted
+	// from cmd and value. It may be called concurrently when
+	// ParallelDynamicVars is set.
+	OnVarResolved func(name, cmd, value string, dur time.Duration)
+	// OnVarCacheHit, when set, is called every time the cached result of a
+	// dynamic variable is used instead of running its command, including
+	// results loaded from the persisted cache. Like OnVarResolved, secrets are
+	// redacted and it may be called concurrently.
+	OnVarCacheHit func(name, cmd, value string)
+	// VarsDumpFormats are formats of DumpVars, by name, in addition to the
+	// "env" and "json" ones. They take precedence over those of the same name.
+	VarsDumpFormats map[string]VarsDumper
+	// DumpSecretVars makes DumpVars write the values of secret variables
+	// instead of masking them.
+	DumpSecretVars bool
+	// TaskvarsFiles are files declaring global variables. Variables of later
+	// files override those of earlier ones.
+	TaskvarsFiles []TaskvarsFile
+	// RunTime is the time the run started, given to templates by the RUN_TIME
+	// special variable and the runTime functions, so that they all see the
+	// same time. Defaults to the time Setup is called.
+	RunTime time.Time
+	// SafeTemplateFuncs restricts the functions available to templates to
+	// those that don't access the environment, files or the network, for
+	// Taskfiles from untrusted sources. See templater.SafeFuncNames.
+	SafeTemplateFuncs bool
+	// EnvAllow restricts the environment variables available as variables to
+	// those matching one of these names, or prefixes followed by "*" (e.g.
+	// "TASK_*"). Commands still run with the whole environment. All of them
+	// are available when it is empty.
+	EnvAllow []string
+	// EnvDeny are names or prefixes of environment variables that are not
+	// available as variables. They are added to a default list of variables
+	// set by shells, like "_".
+	EnvDeny []string
+	// EnvNamespace, when set, is the name of a map variable holding the
+	// environment variables available as variables, e.g. "env" to reference
+	// {{.env.PATH}}. Unlike {{.PATH}}, which still works, it can't be shadowed
+	// by a variable of the same name declared in the Taskfile.
+	EnvNamespace string
+	// VarTransform, if set, is called with each variable declared in the
+	// Taskfile, its includes and calls once its value is resolved, e.g. to
+	// decrypt values. The returned variable is used instead, including by the
+	// variables referencing it. Returning an error makes resolving the
+	// variables of the task fail. Environment and special variables are not
+	// passed to it.
+	VarTransform func(name string, v ast.Var) (ast.Var, error)
+	// StrictTemplates makes templates referencing a variable that is not set
+	// fail with an error naming it, instead of rendering an empty string.
+	// Variables that may not be set can still be read with index, e.g.
+	// {{index . "NAME"}}.
+	StrictTemplates bool
+	// WarnVarNames makes Task warn about variables declared with a name that
+	// can't be referenced as {{.NAME}} (e.g. "MY-VAR").
+	WarnVarNames bool
+	// StrictVarNames makes variables declared with such a name an error
+	// instead.
+	StrictVarNames bool
+	// CLIArgs are extra arguments forwarded to commands through the CLI_ARGS
+	// special variable, which contains them shell quoted and separated by
+	// spaces. When set, even to an empty list, it overrides a CLI_ARGS
+	// variable declared in the Taskfile.
+	CLIArgs []string
+
 	Stdin  io.Reader
 	Stdout io.Writer
 	Stderr io.Writer
@@ -77,7 +168,8 @@ type Executor struct {
 	TaskSorter     sort.TaskSorter
 	UserWorkingDir string
 
-	fuzzyModel *fuzzy.Model
+	fuzzyModel  *fuzzy.Model
+	customFuncs template.FuncMap
 
 	concurrencySemaphore chan struct{}
 	taskCallCount        map[string]*int32
@@ -88,6 +180,12 @@ type Executor struct {
 
 // Run runs Task
 func (e *Executor) Run(ctx context.Context, calls ...*ast.Call) error {
+	defer func() {
+		if err := e.SaveDynamicCache(); err != nil {
+			e.Logger.Errf(logger.Red, "task: unable to save dynamic variables cache: %v\n", err)
+		}
+	}()
+
 	// check if given tasks exist
 	for _, call := range calls {
 		task, err := e.GetTask(call)
@@ -176,7 +274,7 @@ func (e *Executor) RunTask(ctx context.Context, call *ast.Call) error {
 		return nil
 	}
 
-	t, err = e.CompiledTask(call)
+	t, err = e.CompiledTaskContext(ctx, call)
 	if err != nil {
 		return err
 	}
@@ -202,7 +300,7 @@ func (e *Executor) RunTask(ctx context.Context, call *ast.Call) error {
 				return err
 			}
 
-			if err := e.areTaskRequiredVarsSet(t, call); err != nil {
+			if err := e.areTaskRequiredVarsSet(ctx, t, call); err != nil {
 				return err
 			}
 
@@ -333,7 +431,11 @@ func (e *Executor) runDeferred(t *ast.Task, call *ast.Call, i int, deferredExitC
 
 	cmd := t.Cmds[i]
 	vars, _ := e.Compiler.GetVariables(origTask, call)
-	cache := &templater.Cache{Vars: vars}
+	if err := e.Compiler.ResolveLazyVars(ctx, vars, e.Compiler.LazyVarRefs(vars, origTask)...); err != nil {
+		e.Logger.VerboseErrf(logger.Yellow, "task: ignored error in deferred cmd: %s\n", err.Error())
+		return
+	}
+	cache := &templater.Cache{Vars: vars, Delims: cmp.Or(origTask.Delims, e.Taskfile.Delims), Funcs: e.Compiler.Funcs, Strict: e.StrictTemplates}
 	extra := map[string]any{}
 
 	if deferredExitCode != nil && *deferredExitCode > 0 {
@@ -379,7 +481,7 @@ func (e *Executor) runCommand(ctx context.Context, t *ast.Task, call *ast.Call,
 			outputWrapper = output.Interleaved{}
 		}
 		vars, err := e.Compiler.FastGetVariables(t, call)
-		outputTemplater := &templater.Cache{Vars: vars}
+		outputTemplater := &templater.Cache{Vars: vars, Delims: cmp.Or(t.Delims, e.Taskfile.Delims), Funcs: e.Compiler.Funcs}
 		if err != nil {
 			return fmt.Errorf("task: failed to get variables: %w", err)
 		}
@@ -6,4 +6,7 @@ type Call struct {
 	Vars     *Vars
 	Silent   bool
 	Indirect bool // True if the task was called by another task
+	// Args are the positional arguments of the call, available to the task
+	// as the ARGS list. When nil, the CLI arguments are used.
+	Args []string
 }
@@ -0,0 +1,45 @@
+package ast
+
+import (
+	"gopkg.in/yaml.v3"
+
+	"github.com/go-task/task/v3/errors"
+)
+
+// Delims are the action delimiters used by the templating engine. When unset,
+// the default "{{" and "}}" delimiters are used.
+type Delims struct {
+	Left  string
+	Right string
+}
+
+// orDefault returns d, or the default delimiters when d is unset.
+func (d Delims) orDefault() Delims {
+	if d == (Delims{}) {
+		return Delims{Left: "{{", Right: "}}"}
+	}
+	return d
+}
+
+func (d *Delims) UnmarshalYAML(node *yaml.Node) error {
+	switch node.Kind {
+	case yaml.MappingNode:
+		var tmp struct {
+			Left  string
+			Right string
+		}
+		if err := node.Decode(&tmp); err != nil {
+			return errors.NewTaskfileDecodeError(err, node)
+		}
+		if tmp.Left == "" || tmp.Right == "" {
+			return errors.NewTaskfileDecodeError(nil, node).WithMessage(`delims must have both the "left" and "right" keys`)
+		}
+		*d = Delims{
+			Left:  tmp.Left,
+			Right: tmp.Right,
+		}
+		return nil
+	}
+
+	return errors.NewTaskfileDecodeError(nil, node).WithTypeMessage("delims")
+}
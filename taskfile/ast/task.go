@@ -42,10 +42,13 @@ type Task struct {
 	Platforms     []*Platform
 	Watch         bool
 	Location      *Location
+	// Delims are the template delimiters of the Taskfile declaring the task
+	Delims Delims
 	// Populated during merging
 	Namespace            string
 	IncludeVars          *Vars
 	IncludedTaskfileVars *Vars
+	ParentTaskfileVars   *Vars
 }
 
 func (t *Task) Name() string {
@@ -216,8 +219,10 @@ func (t *Task) DeepCopy() *Task {
 		Run:                  t.Run,
 		IncludeVars:          t.IncludeVars.DeepCopy(),
 		IncludedTaskfileVars: t.IncludedTaskfileVars.DeepCopy(),
+		ParentTaskfileVars:   t.ParentTaskfileVars.DeepCopy(),
 		Platforms:            deepcopy.Slice(t.Platforms),
 		Location:             t.Location.DeepCopy(),
+		Delims:               t.Delims,
 		Requires:             t.Requires.DeepCopy(),
 		Namespace:            t.Namespace,
 	}
@@ -34,6 +34,11 @@ type Taskfile struct {
 	Dotenv   []string
 	Run      string
 	Interval time.Duration
+	Delims   Delims
+
+	// declaredVars are the variables declared by the Taskfile itself, before
+	// the variables of the Taskfiles it includes are merged into Vars
+	declaredVars *Vars
 }
 
 // Merge merges the second Taskfile into the first
@@ -53,9 +58,39 @@ func (t1 *Taskfile) Merge(t2 *Taskfile, include *Include) error {
 	if t1.Env == nil {
 		t1.Env = &Vars{}
 	}
+	if t1.declaredVars == nil {
+		t1.declaredVars = t1.Vars.DeepCopy()
+	}
 	t1.Vars.Merge(t2.Vars, include)
 	t1.Env.Merge(t2.Env, include)
-	return t1.Tasks.Merge(t2.Tasks, include, t1.Vars)
+	return t1.Tasks.Merge(t2.Tasks, include, t2.DeclaredVars(), t1.DeclaredVars())
+}
+
+// DeclaredVars returns the variables declared by the Taskfile itself, without
+// the variables of the Taskfiles it includes.
+func (tf *Taskfile) DeclaredVars() *Vars {
+	if tf.declaredVars != nil {
+		return tf.declaredVars
+	}
+	return tf.Vars
+}
+
+// setDelims records the delimiters of the Taskfile in its tasks and in the
+// variables it declares, so that they are still templated with them once
+// merged with the ones of other Taskfiles.
+func (tf *Taskfile) setDelims() {
+	delims := tf.Delims.orDefault()
+	tf.Vars.setDelims(delims)
+	tf.Env.setDelims(delims)
+	_ = tf.Includes.Range(func(_ string, include *Include) error {
+		include.Vars.setDelims(delims)
+		return nil
+	})
+	_ = tf.Tasks.Range(func(_ string, t *Task) error {
+		t.Delims = delims
+		t.Vars.setDelims(delims)
+		return nil
+	})
 }
 
 func (tf *Taskfile) UnmarshalYAML(node *yaml.Node) error {
@@ -75,6 +110,7 @@ func (tf *Taskfile) UnmarshalYAML(node *yaml.Node) error {
 			Dotenv   []string
 			Run      string
 			Interval time.Duration
+			Delims   Delims
 		}
 		if err := node.Decode(&taskfile); err != nil {
 			return errors.NewTaskfileDecodeError(err, node)
@@ -92,12 +128,14 @@ func (tf *Taskfile) UnmarshalYAML(node *yaml.Node) error {
 		tf.Dotenv = taskfile.Dotenv
 		tf.Run = taskfile.Run
 		tf.Interval = taskfile.Interval
+		tf.Delims = taskfile.Delims
 		if tf.Vars == nil {
 			tf.Vars = &Vars{}
 		}
 		if tf.Env == nil {
 			tf.Env = &Vars{}
 		}
+		tf.setDelims()
 		return nil
 	}
 
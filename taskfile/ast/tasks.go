@@ -47,7 +47,7 @@ func (t *Tasks) FindMatchingTasks(call *Call) []*MatchingTask {
 	return matchingTasks
 }
 
-func (t1 *Tasks) Merge(t2 Tasks, include *Include, includedTaskfileVars *Vars) error {
+func (t1 *Tasks) Merge(t2 Tasks, include *Include, includedTaskfileVars, parentTaskfileVars *Vars) error {
 	err := t2.Range(func(name string, v *Task) error {
 		// We do a deep copy of the task struct here to ensure that no data can
 		// be changed elsewhere once the taskfile is merged.
@@ -97,7 +97,25 @@ func (t1 *Tasks) Merge(t2 Tasks, include *Include, includedTaskfileVars *Vars) e
 				task.IncludeVars = &Vars{}
 			}
 			task.IncludeVars.Merge(include.Vars, nil)
-			task.IncludedTaskfileVars = includedTaskfileVars.DeepCopy()
+
+			// Tasks of Taskfiles included further down keep the variables of
+			// their own Taskfile, but inherit the ones of each Taskfile
+			// including it. The variables of the closest Taskfile take
+			// precedence.
+			if task.IncludedTaskfileVars == nil {
+				task.IncludedTaskfileVars = &Vars{}
+				task.IncludedTaskfileVars.Merge(includedTaskfileVars, include)
+			} else {
+				vars := &Vars{}
+				vars.Merge(task.IncludedTaskfileVars, include)
+				task.IncludedTaskfileVars = vars
+			}
+			parentVars := parentTaskfileVars.DeepCopy()
+			if parentVars == nil {
+				parentVars = &Vars{}
+			}
+			parentVars.Merge(task.ParentTaskfileVars, include)
+			task.ParentTaskfileVars = parentVars
 		}
 
 		if t1.Get(taskName) != nil {
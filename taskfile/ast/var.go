@@ -1,12 +1,17 @@
 package ast
 
 import (
+	"runtime"
+	"slices"
 	"strings"
+	"time"
 
 	"gopkg.in/yaml.v3"
 
 	"github.com/go-task/task/v3/errors"
 	"github.com/go-task/task/v3/internal/experiments"
+	"github.com/go-task/task/v3/internal/filepathext"
+	"github.com/go-task/task/v3/internal/goext"
 	"github.com/go-task/task/v3/internal/omap"
 )
 
@@ -51,13 +56,25 @@ func (vs *Vars) Merge(other *Vars, include *Include) {
 	}
 	_ = other.Range(func(key string, value Var) error {
 		if include != nil && include.AdvancedImport {
-			value.Dir = include.Dir
+			value.Dir = filepathext.SmartJoin(include.Dir, value.Dir)
 		}
 		vs.Set(key, value)
 		return nil
 	})
 }
 
+// setDelims sets the template delimiters of all the variables.
+func (vs *Vars) setDelims(delims Delims) {
+	if vs == nil {
+		return
+	}
+	for _, k := range vs.Keys() {
+		v := vs.Get(k)
+		v.Delims = delims
+		vs.Set(k, v)
+	}
+}
+
 // Wrapper around OrderedMap.Len to ensure we don't get nil pointer errors
 func (vs *Vars) Len() int {
 	if vs == nil {
@@ -79,13 +96,111 @@ func (vs *Vars) DeepCopy() *Vars {
 
 // Var represents either a static or dynamic variable.
 type Var struct {
-	Value any
-	Live  any
-	Sh    *string
-	Ref   string
-	Dir   string
+	Value   any
+	Live    any
+	Sh      *string
+	Ref     string
+	File    string
+	Glob    string
+	Dir     string
+	Split   bool
+	Trim    string
+	Encode  string
+	Timeout time.Duration
+	Shell   string
+	Persist bool
+	TTL     time.Duration
+	Type    string
+	Secret  bool
+	Stderr  string
+	Stdin   string
+	// NonEmpty makes a dynamic variable fail when the output of its command
+	// is empty or only made of whitespace.
+	NonEmpty bool
+	// Optional makes a dynamic variable empty, instead of failing, when its
+	// command fails.
+	Optional bool
+	// Export makes the output of a dynamic variable be read as KEY=VALUE
+	// lines, each declaring a variable. The variable itself is set to a map of
+	// them.
+	Export bool
+	// When is the name of a variable that must be set, and not empty, for
+	// this variable to be declared. Otherwise, it is skipped.
+	When string
+	// Merge is how the value of a list variable is merged with the value of
+	// the variable of the same name from a previous layer (e.g. a global
+	// variable overridden by a task variable). See the Merge constants.
+	Merge string
+	// Delims are the template delimiters of the Taskfile declaring the
+	// variable. When unset, the ones of the main Taskfile are used.
+	Delims Delims
+	// Lazy makes the command of a dynamic variable run only when the variable
+	// is referenced, instead of when the variables of a task are resolved.
+	Lazy bool
+	// Retries is how many times the command is run again after it exits with
+	// a non-zero code, waiting RetryDelay in between.
+	Retries    int
+	RetryDelay time.Duration
+	// Source is the layer a resolved variable comes from, and Dynamic reports
+	// whether its value is the output of a command. They are set on the
+	// variables resolved by the compiler, except for Source, which is kept if
+	// it is already set.
+	Source  VarSource
+	Dynamic bool
 }
 
+// Strategies to merge list variables with the variable of the same name from a
+// previous layer.
+const (
+	MergeReplace = "replace"
+	MergeAppend  = "append"
+)
+
+// Types the output of a dynamic variable can be converted to.
+const (
+	TypeString = "string"
+	TypeBool   = "bool"
+	TypeInt    = "int"
+)
+
+// Trim modes that can be applied to the output of a dynamic variable.
+const (
+	TrimNone    = "none"
+	TrimSpace   = "space"
+	TrimNewline = "newline"
+)
+
+// Encodings that can be applied to the output of a dynamic variable.
+const (
+	EncodeNone   = "none"
+	EncodeBase64 = "base64"
+	EncodeHex    = "hex"
+)
+
+// Modes of handling what the command of a dynamic variable writes to stderr.
+const (
+	StderrForward = "forward"
+	StderrCapture = "capture"
+)
+
+// VarSource is where the final value of a resolved variable is declared.
+type VarSource string
+
+// Sources of resolved variables, from the lowest to the highest precedence.
+const (
+	VarSourceEnviron          VarSource = "environ"
+	VarSourceSpecial          VarSource = "special"
+	VarSourceTaskfileEnv      VarSource = "taskfile env"
+	VarSourceTaskvars         VarSource = "taskvars"
+	VarSourceTaskfile         VarSource = "taskfile"
+	VarSourceParentTaskfile   VarSource = "parent taskfile"
+	VarSourceInclude          VarSource = "include"
+	VarSourceIncludedTaskfile VarSource = "included taskfile"
+	VarSourceCall             VarSource = "call"
+	VarSourceTask             VarSource = "task"
+	VarSourceOverride         VarSource = "override"
+)
+
 func (v *Var) UnmarshalYAML(node *yaml.Node) error {
 	if experiments.MapVariables.Enabled {
 
@@ -97,6 +212,12 @@ func (v *Var) UnmarshalYAML(node *yaml.Node) error {
 			}
 			// If the value is a string and it starts with $, then it's a shell command
 			if str, ok := value.(string); ok {
+				// A leading backslash escapes the prefix, e.g. "\$HOME" is the
+				// literal "$HOME"
+				if rest, ok := strings.CutPrefix(str, `\`); ok && (strings.HasPrefix(rest, "$") || strings.HasPrefix(rest, "#")) {
+					v.Value = rest
+					return nil
+				}
 				if str, ok = strings.CutPrefix(str, "$"); ok {
 					v.Sh = &str
 					return nil
@@ -117,18 +238,7 @@ func (v *Var) UnmarshalYAML(node *yaml.Node) error {
 				key := node.Content[0].Value
 				switch key {
 				case "sh", "ref", "map":
-					var m struct {
-						Sh  *string
-						Ref string
-						Map any
-					}
-					if err := node.Decode(&m); err != nil {
-						return errors.NewTaskfileDecodeError(err, node)
-					}
-					v.Sh = m.Sh
-					v.Ref = m.Ref
-					v.Value = m.Map
-					return nil
+					return v.decodeSubkeys(node, true)
 				default:
 					return errors.NewTaskfileDecodeError(nil, node).WithMessage(`%q is not a valid variable type. Try "sh", "ref", "map" or using a scalar value`, key)
 				}
@@ -146,22 +256,26 @@ func (v *Var) UnmarshalYAML(node *yaml.Node) error {
 	switch node.Kind {
 
 	case yaml.MappingNode:
-		key := node.Content[0].Value
-		switch key {
-		case "sh", "ref":
-			var m struct {
-				Sh  *string
-				Ref string
+		// Mappings containing the "sh", "ref", "file", "glob", "list" or
+		// "when" keys declare a dynamic, reference, file, glob, list or
+		// conditional variable with options, and mappings whose keys are
+		// operating systems declare a value per platform. Any other mapping is
+		// a map variable.
+		for i := 0; i < len(node.Content); i += 2 {
+			switch node.Content[i].Value {
+			case "sh", "ref", "file", "glob", "list", "when":
+				return v.decodeSubkeys(node, false)
 			}
-			if err := node.Decode(&m); err != nil {
-				return errors.NewTaskfileDecodeError(err, node)
-			}
-			v.Sh = m.Sh
-			v.Ref = m.Ref
-			return nil
-		default:
-			return errors.NewTaskfileDecodeError(nil, node).WithMessage("maps cannot be assigned to variables")
 		}
+		if isPlatformMapping(node) {
+			return v.decodePlatforms(node)
+		}
+		var value map[string]any
+		if err := node.Decode(&value); err != nil {
+			return errors.NewTaskfileDecodeError(err, node)
+		}
+		v.Value = value
+		return nil
 
 	default:
 		var value any
@@ -172,3 +286,180 @@ func (v *Var) UnmarshalYAML(node *yaml.Node) error {
 		return nil
 	}
 }
+
+// isPlatformMapping reports whether all the keys of node are operating systems
+// (e.g. "linux" or "windows") or "default", with at least one operating
+// system.
+func isPlatformMapping(node *yaml.Node) bool {
+	hasOS := false
+	for i := 0; i < len(node.Content); i += 2 {
+		key := node.Content[i].Value
+		switch {
+		case goext.IsKnownOS(key):
+			hasOS = true
+		case key != "default":
+			return false
+		}
+	}
+	return hasOS
+}
+
+// decodePlatforms decodes a variable declaring a value per operating system.
+// The value of the current operating system is used, or else the "default"
+// value. Each value is declared like any other variable.
+func (v *Var) decodePlatforms(node *yaml.Node) error {
+	var defaultNode *yaml.Node
+	for i := 0; i < len(node.Content); i += 2 {
+		switch node.Content[i].Value {
+		case runtime.GOOS:
+			return v.UnmarshalYAML(node.Content[i+1])
+		case "default":
+			defaultNode = node.Content[i+1]
+		}
+	}
+	if defaultNode == nil {
+		return errors.NewTaskfileDecodeError(nil, node).WithMessage(`no value for the %q operating system. Add one or a "default" value`, runtime.GOOS)
+	}
+	return v.UnmarshalYAML(defaultNode)
+}
+
+// varKindKeys are the keys declaring what the value of a variable declared
+// with options is. Only one of them can be used at a time.
+var varKindKeys = []string{"sh", "ref", "file", "glob", "list", "value", "map"}
+
+// varOptionKeys are the other keys a variable declared with options can have.
+var varOptionKeys = []string{
+	"dir", "when", "export", "non_empty", "optional", "merge", "split", "trim",
+	"encode", "timeout", "shell", "persist", "ttl", "type", "secret", "stderr",
+	"stdin", "lazy", "retries", "retry_delay",
+}
+
+// decodeSubkeys decodes a variable declared using one of the "sh", "ref",
+// "file", "glob", "list", "when" or "map" keys, along with any options given
+// alongside them. Unknown keys and keys declaring different kinds of values
+// are errors, so that a map variable is never silently mistaken for a
+// variable with options.
+func (v *Var) decodeSubkeys(node *yaml.Node, allowMap bool) error {
+	var kinds []string
+	for i := 0; i < len(node.Content); i += 2 {
+		key := node.Content[i].Value
+		switch {
+		case slices.Contains(varKindKeys, key) && (key != "map" || allowMap):
+			kinds = append(kinds, key)
+		case !slices.Contains(varOptionKeys, key):
+			return errors.NewTaskfileDecodeError(nil, node.Content[i]).WithMessage(`%q is not a valid variable option`, key)
+		}
+	}
+	if len(kinds) > 1 {
+		return errors.NewTaskfileDecodeError(nil, node).WithMessage(`%q and %q can't be used together`, kinds[0], kinds[1])
+	}
+	var m struct {
+		Sh         *string
+		Ref        string
+		File       string
+		Glob       string
+		Dir        string
+		Map        any
+		List       []any
+		Value      any
+		When       string
+		Export     bool
+		NonEmpty   bool `yaml:"non_empty"`
+		Optional   bool
+		Merge      string
+		Split      bool
+		Trim       string
+		Encode     string
+		Timeout    time.Duration
+		Shell      string
+		Persist    bool
+		TTL        time.Duration
+		Type       string
+		Secret     bool
+		Stderr     string
+		Stdin      string
+		Lazy       bool
+		Retries    int
+		RetryDelay time.Duration `yaml:"retry_delay"`
+	}
+	if err := node.Decode(&m); err != nil {
+		return errors.NewTaskfileDecodeError(err, node)
+	}
+	switch m.Trim {
+	case "", TrimNone, TrimSpace, TrimNewline:
+	default:
+		return errors.NewTaskfileDecodeError(nil, node).WithMessage(`%q is not a valid trim mode. Try "none", "space" or "newline"`, m.Trim)
+	}
+	switch m.Encode {
+	case "", EncodeNone, EncodeBase64, EncodeHex:
+	default:
+		return errors.NewTaskfileDecodeError(nil, node).WithMessage(`%q is not a valid encoding. Try "none", "base64" or "hex"`, m.Encode)
+	}
+	if m.When != "" && m.Value == nil && m.Sh == nil && m.Ref == "" && m.File == "" && m.Glob == "" && m.List == nil {
+		return errors.NewTaskfileDecodeError(nil, node).WithMessage(`"when" must be used with "value", "sh", "ref", "file", "glob" or "list"`)
+	}
+	if m.NonEmpty && m.Sh == nil {
+		return errors.NewTaskfileDecodeError(nil, node).WithMessage(`"non_empty" can only be used with "sh"`)
+	}
+	if m.Optional && m.Sh == nil {
+		return errors.NewTaskfileDecodeError(nil, node).WithMessage(`"optional" can only be used with "sh"`)
+	}
+	if m.Export && (m.Sh == nil || m.Split || m.Lazy) {
+		return errors.NewTaskfileDecodeError(nil, node).WithMessage(`"export" can only be used with "sh", and not with "split" or "lazy"`)
+	}
+	switch m.Merge {
+	case "", MergeReplace, MergeAppend:
+	default:
+		return errors.NewTaskfileDecodeError(nil, node).WithMessage(`%q is not a valid merge strategy. Try "replace" or "append"`, m.Merge)
+	}
+	switch m.Stderr {
+	case "", StderrForward, StderrCapture:
+	default:
+		return errors.NewTaskfileDecodeError(nil, node).WithMessage(`%q is not a valid stderr mode. Try "forward" or "capture"`, m.Stderr)
+	}
+	if m.Lazy && m.Sh == nil {
+		return errors.NewTaskfileDecodeError(nil, node).WithMessage(`"lazy" can only be used with "sh"`)
+	}
+	if m.Retries < 0 {
+		return errors.NewTaskfileDecodeError(nil, node).WithMessage(`%d is not a valid number of retries. It can't be negative`, m.Retries)
+	}
+	switch m.Type {
+	case "", TypeString, TypeBool, TypeInt:
+	default:
+		return errors.NewTaskfileDecodeError(nil, node).WithMessage(`%q is not a valid variable type. Try "string", "bool" or "int"`, m.Type)
+	}
+	v.Sh = m.Sh
+	v.Ref = m.Ref
+	v.File = m.File
+	v.Glob = m.Glob
+	v.Dir = m.Dir
+	if allowMap {
+		v.Value = m.Map
+	}
+	if m.Value != nil {
+		v.Value = m.Value
+	}
+	if m.List != nil {
+		v.Value = m.List
+	}
+	v.When = m.When
+	v.Export = m.Export
+	v.NonEmpty = m.NonEmpty
+	v.Optional = m.Optional
+	v.Merge = m.Merge
+	v.Split = m.Split
+	v.Trim = m.Trim
+	v.Encode = m.Encode
+	v.Timeout = m.Timeout
+	v.Shell = m.Shell
+	v.Persist = m.Persist
+	v.TTL = m.TTL
+	v.Type = m.Type
+	v.Secret = m.Secret
+	v.Stderr = m.Stderr
+	v.Stdin = m.Stdin
+	v.Lazy = m.Lazy
+	v.Retries = m.Retries
+	v.RetryDelay = m.RetryDelay
+	return nil
+}
@@ -0,0 +1,338 @@
+package ast_test
+
+import (
+	"fmt"
+	"runtime"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"gopkg.in/yaml.v3"
+
+	"github.com/go-task/task/v3/internal/experiments"
+	"github.com/go-task/task/v3/taskfile/ast"
+)
+
+func TestVarParse(t *testing.T) {
+	sh := func(s string) *string { return &s }
+	tests := []struct {
+		content  string
+		expected ast.Var
+	}{
+		{
+			"foo",
+			ast.Var{Value: "foo"},
+		},
+		{
+			"sh: echo foo",
+			ast.Var{Sh: sh("echo foo")},
+		},
+		{
+			"$echo foo",
+			ast.Var{Value: "$echo foo"},
+		},
+		{
+			`
+sh: git ls-files
+split: true
+`,
+			ast.Var{Sh: sh("git ls-files"), Split: true},
+		},
+		{
+			`
+list: [c]
+merge: append
+`,
+			ast.Var{Value: []any{"c"}, Merge: ast.MergeAppend},
+		},
+		{
+			`
+value: --registry {{.REGISTRY}}
+when: REGISTRY
+`,
+			ast.Var{Value: "--registry {{.REGISTRY}}", When: "REGISTRY"},
+		},
+		{
+			`
+sh: echo foo
+trim: none
+`,
+			ast.Var{Sh: sh("echo foo"), Trim: ast.TrimNone},
+		},
+		{
+			`
+sh: echo true
+type: bool
+`,
+			ast.Var{Sh: sh("echo true"), Type: ast.TypeBool},
+		},
+		{
+			`
+split: true
+sh: git ls-files
+`,
+			ast.Var{Sh: sh("git ls-files"), Split: true},
+		},
+		{
+			`
+sh: curl https://example.com
+retries: 3
+retry_delay: 2s
+`,
+			ast.Var{Sh: sh("curl https://example.com"), Retries: 3, RetryDelay: 2 * time.Second},
+		},
+		{
+			`
+sh: git rev-parse HEAD
+optional: true
+`,
+			ast.Var{Sh: sh("git rev-parse HEAD"), Optional: true},
+		},
+		{
+			`
+sh: cat version
+dir: web
+`,
+			ast.Var{Sh: sh("cat version"), Dir: "web"},
+		},
+		{
+			`
+sh: openssl rand 32
+encode: base64
+`,
+			ast.Var{Sh: sh("openssl rand 32"), Encode: ast.EncodeBase64},
+		},
+		{
+			"glob: '**/*.go'",
+			ast.Var{Glob: "**/*.go"},
+		},
+		{
+			`
+file: secret.txt
+trim: space
+secret: true
+`,
+			ast.Var{File: "secret.txt", Trim: ast.TrimSpace, Secret: true},
+		},
+		{
+			`
+env: prod
+labels:
+  tier: web
+`,
+			ast.Var{Value: map[string]any{
+				"env":    "prod",
+				"labels": map[string]any{"tier": "web"},
+			}},
+		},
+		{
+			fmt.Sprintf(`
+plan9: plan9
+%s: current
+default: default
+`, runtime.GOOS),
+			ast.Var{Value: "current"},
+		},
+		{
+			fmt.Sprintf(`
+default: default
+%s:
+  sh: echo current
+`, runtime.GOOS),
+			ast.Var{Sh: sh("echo current")},
+		},
+		{
+			`
+plan9: plan9
+default: [a, b]
+`,
+			ast.Var{Value: []any{"a", "b"}},
+		},
+		{
+			`
+linux: linux
+other: other
+`,
+			ast.Var{Value: map[string]any{"linux": "linux", "other": "other"}},
+		},
+	}
+	for _, test := range tests {
+		var v ast.Var
+		err := yaml.Unmarshal([]byte(test.content), &v)
+		require.NoError(t, err)
+		assert.Equal(t, test.expected, v)
+	}
+}
+
+func TestVarParseMapVariables(t *testing.T) {
+	mapVariables := experiments.MapVariables
+	experiments.MapVariables = experiments.Experiment{Name: "MAP_VARIABLES", Enabled: true, Value: "1"}
+	t.Cleanup(func() { experiments.MapVariables = mapVariables })
+
+	sh := func(s string) *string { return &s }
+	tests := []struct {
+		content  string
+		expected ast.Var
+	}{
+		{
+			"$echo foo",
+			ast.Var{Sh: sh("echo foo")},
+		},
+		{
+			"'$echo foo'",
+			ast.Var{Sh: sh("echo foo")},
+		},
+		{
+			"'#.FOO'",
+			ast.Var{Ref: ".FOO"},
+		},
+		{
+			`\$HOME-literal`,
+			ast.Var{Value: "$HOME-literal"},
+		},
+		{
+			`'\$HOME-literal'`,
+			ast.Var{Value: "$HOME-literal"},
+		},
+		{
+			`"\\#channel"`,
+			ast.Var{Value: "#channel"},
+		},
+		{
+			`\foo`,
+			ast.Var{Value: `\foo`},
+		},
+	}
+	for _, test := range tests {
+		var v ast.Var
+		err := yaml.Unmarshal([]byte(test.content), &v)
+		require.NoError(t, err)
+		assert.Equal(t, test.expected, v)
+	}
+}
+
+func TestVarParseErrors(t *testing.T) {
+	tests := []struct {
+		content       string
+		expectedError string
+	}{
+		{
+			`
+sh: echo foo
+trim: both
+`,
+			`"both" is not a valid trim mode`,
+		},
+		{
+			`
+sh: echo 1
+type: float
+`,
+			`"float" is not a valid variable type`,
+		},
+		{
+			`
+sh: echo 1
+stderr: discard
+`,
+			`"discard" is not a valid stderr mode`,
+		},
+		{
+			`
+sh: echo 1
+encode: base32
+`,
+			`"base32" is not a valid encoding`,
+		},
+		{
+			`
+list: [c]
+merge: prepend
+`,
+			`"prepend" is not a valid merge strategy`,
+		},
+		{
+			`
+file: version.txt
+non_empty: true
+`,
+			`"non_empty" can only be used with "sh"`,
+		},
+		{
+			`
+file: version.txt
+optional: true
+`,
+			`"optional" can only be used with "sh"`,
+		},
+		{
+			`
+sh: ./info.sh
+export: true
+split: true
+`,
+			`"export" can only be used with "sh", and not with "split" or "lazy"`,
+		},
+		{
+			`
+when: REGISTRY
+`,
+			`"when" must be used with "value", "sh", "ref", "file", "glob" or "list"`,
+		},
+		{
+			`
+sh: echo 1
+retries: -1
+`,
+			`-1 is not a valid number of retries`,
+		},
+		{
+			`
+file: version.txt
+lazy: true
+`,
+			`"lazy" can only be used with "sh"`,
+		},
+		{
+			`
+plan9: plan9
+`,
+			fmt.Sprintf(`no value for the %q operating system`, runtime.GOOS),
+		},
+		{
+			`
+env: prod
+sh: echo foo
+`,
+			`"env" is not a valid variable option`,
+		},
+		{
+			`
+file: a.txt
+owner: me
+`,
+			`"owner" is not a valid variable option`,
+		},
+		{
+			`
+sh: echo foo
+file: a.txt
+`,
+			`"sh" and "file" can't be used together`,
+		},
+		{
+			`
+list: [a]
+value: b
+when: REGISTRY
+`,
+			`"list" and "value" can't be used together`,
+		},
+	}
+	for _, test := range tests {
+		var v ast.Var
+		err := yaml.Unmarshal([]byte(test.content), &v)
+		require.ErrorContains(t, err, test.expectedError)
+	}
+}
@@ -23,7 +23,7 @@ func Dotenv(c *compiler.Compiler, tf *ast.Taskfile, dir string) (*ast.Vars, erro
 	}
 
 	env := &ast.Vars{}
-	cache := &templater.Cache{Vars: vars}
+	cache := &templater.Cache{Vars: vars, Delims: tf.Delims, Funcs: c.Funcs}
 
 	for _, dotEnvPath := range tf.Dotenv {
 		dotEnvPath = templater.Replace(dotEnvPath, cache)
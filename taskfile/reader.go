@@ -17,6 +17,7 @@ import (
 	"github.com/go-task/task/v3/internal/logger"
 	"github.com/go-task/task/v3/internal/templater"
 	"github.com/go-task/task/v3/taskfile/ast"
+	"github.com/go-task/template"
 )
 
 const (
@@ -39,6 +40,7 @@ type Reader struct {
 	timeout     time.Duration
 	tempDir     string
 	logger      *logger.Logger
+	funcs       template.FuncMap
 	promptMutex sync.Mutex
 }
 
@@ -50,6 +52,7 @@ func NewReader(
 	timeout time.Duration,
 	tempDir string,
 	logger *logger.Logger,
+	funcs template.FuncMap,
 ) *Reader {
 	return &Reader{
 		graph:       ast.NewTaskfileGraph(),
@@ -60,6 +63,7 @@ func NewReader(
 		timeout:     timeout,
 		tempDir:     tempDir,
 		logger:      logger,
+		funcs:       funcs,
 		promptMutex: sync.Mutex{},
 	}
 }
@@ -105,7 +109,7 @@ func (r *Reader) include(node Node) error {
 		vars.Merge(vertex.Taskfile.Vars, nil)
 		// Start a goroutine to process each included Taskfile
 		g.Go(func() error {
-			cache := &templater.Cache{Vars: vars}
+			cache := &templater.Cache{Vars: vars, Delims: vertex.Taskfile.Delims, Funcs: r.funcs}
 			include = &ast.Include{
 				Namespace:      include.Namespace,
 				Taskfile:       templater.Replace(include.Taskfile, cache),
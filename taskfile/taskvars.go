@@ -0,0 +1,72 @@
+package taskfile
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/BurntSushi/toml"
+	"gopkg.in/yaml.v3"
+
+	"github.com/go-task/task/v3/errors"
+	"github.com/go-task/task/v3/internal/filepathext"
+	"github.com/go-task/task/v3/taskfile/ast"
+)
+
+// Taskvars reads the variables declared in a Taskvars file. The file is a
+// mapping of variables, declared the same way as in the vars key of a
+// Taskfile. Files with a .toml extension are decoded as TOML; any other file
+// is decoded as YAML, which also covers JSON files.
+func Taskvars(path string) (*ast.Vars, error) {
+	b, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("error reading Taskvars file %s: %w", path, err)
+	}
+
+	if strings.EqualFold(filepath.Ext(path), ".toml") {
+		vars, err := decodeTOMLVars(b)
+		if err != nil {
+			return nil, &errors.TaskfileInvalidError{URI: filepathext.TryAbsToRel(path), Err: err}
+		}
+		return vars, nil
+	}
+
+	var vars ast.Vars
+	if err := yaml.Unmarshal(b, &vars); err != nil {
+		taskfileDecodeErr := &errors.TaskfileDecodeError{}
+		if errors.As(err, &taskfileDecodeErr) {
+			return nil, taskfileDecodeErr.WithFileInfo(path, b, 2)
+		}
+		return nil, &errors.TaskfileInvalidError{URI: filepathext.TryAbsToRel(path), Err: err}
+	}
+	return &vars, nil
+}
+
+// decodeTOMLVars decodes TOML variables by converting them to a YAML mapping
+// in declaration order, so they are decoded exactly like YAML variables.
+func decodeTOMLVars(b []byte) (*ast.Vars, error) {
+	var m map[string]any
+	md, err := toml.Decode(string(b), &m)
+	if err != nil {
+		return nil, err
+	}
+
+	node := &yaml.Node{Kind: yaml.MappingNode}
+	for _, key := range md.Keys() {
+		if len(key) != 1 {
+			continue
+		}
+		var value yaml.Node
+		if err := value.Encode(m[key[0]]); err != nil {
+			return nil, err
+		}
+		node.Content = append(node.Content, &yaml.Node{Kind: yaml.ScalarNode, Value: key[0]}, &value)
+	}
+
+	var vars ast.Vars
+	if err := node.Decode(&vars); err != nil {
+		return nil, err
+	}
+	return &vars, nil
+}
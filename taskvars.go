@@ -0,0 +1,69 @@
+package task
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/go-task/task/v3/errors"
+	"github.com/go-task/task/v3/internal/filepathext"
+	"github.com/go-task/task/v3/internal/templater"
+	"github.com/go-task/task/v3/taskfile"
+	"github.com/go-task/task/v3/taskfile/ast"
+)
+
+// TaskvarsFile is a file declaring global variables, in the same format as
+// the vars key of a Taskfile.
+type TaskvarsFile struct {
+	// Path of the file. Relative paths are resolved from the directory of
+	// the root Taskfile. It may be a template (e.g. "Taskvars.{{OS}}.yml")
+	// and reference environment variables (e.g. "$TASKVARS_FILE"), which are
+	// expanded after the template is rendered. Templates can use the template
+	// functions, like OS, ARCH or env, but no variables, not even the special
+	// ones, since the files are read before any variable is resolved.
+	Path string
+	// Optional makes a missing file be ignored instead of being an error.
+	Optional bool
+}
+
+// readTaskvarsFiles merges the variables of the Taskvars files into the
+// global variables of the Taskfile. Later files override earlier ones, and
+// variables declared in the Taskfile override all of them.
+func (e *Executor) readTaskvarsFiles() error {
+	if len(e.TaskvarsFiles) == 0 {
+		return nil
+	}
+
+	vars := &ast.Vars{}
+	for _, f := range e.TaskvarsFiles {
+		path, err := e.taskvarsFilePath(f.Path)
+		if err != nil {
+			return err
+		}
+		fileVars, err := taskfile.Taskvars(filepathext.SmartJoin(e.Dir, path))
+		if f.Optional && errors.Is(err, os.ErrNotExist) {
+			continue
+		}
+		if err != nil {
+			return err
+		}
+		_ = fileVars.Range(func(k string, v ast.Var) error {
+			v.Source = ast.VarSourceTaskvars
+			vars.Set(k, v)
+			return nil
+		})
+	}
+	vars.Merge(e.Taskfile.Vars, nil)
+	e.Taskfile.Vars = vars
+	return nil
+}
+
+// taskvarsFilePath renders the template of the path of a Taskvars file and
+// expands the environment variables it references.
+func (e *Executor) taskvarsFilePath(path string) (string, error) {
+	cache := &templater.Cache{Vars: &ast.Vars{}, Delims: e.Taskfile.Delims, Funcs: e.templateFuncs()}
+	rendered := templater.Replace(path, cache)
+	if err := cache.Err(); err != nil {
+		return "", fmt.Errorf("task: Failed to render the path of the Taskvars file %q: %w", path, err)
+	}
+	return os.ExpandEnv(rendered), nil
+}
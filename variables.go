@@ -1,13 +1,21 @@
 package task
 
 import (
+	"cmp"
+	"context"
+	"fmt"
+	"maps"
 	"os"
 	"path/filepath"
+	"regexp"
+	"slices"
 	"strings"
 
 	"github.com/joho/godotenv"
 
 	"github.com/go-task/task/v3/errors"
+	"github.com/go-task/task/v3/internal/compiler"
+	"github.com/go-task/task/v3/internal/env"
 	"github.com/go-task/task/v3/internal/execext"
 	"github.com/go-task/task/v3/internal/filepathext"
 	"github.com/go-task/task/v3/internal/fingerprint"
@@ -19,15 +27,255 @@ import (
 // CompiledTask returns a copy of a task, but replacing variables in almost all
 // properties using the Go template package.
 func (e *Executor) CompiledTask(call *ast.Call) (*ast.Task, error) {
-	return e.compiledTask(call, true)
+	return e.compiledTask(context.Background(), call, true)
+}
+
+// CompiledTaskContext is like CompiledTask, but stops resolving variables and
+// returns the error of ctx as soon as it is done.
+func (e *Executor) CompiledTaskContext(ctx context.Context, call *ast.Call) (*ast.Task, error) {
+	return e.compiledTask(ctx, call, true)
 }
 
 // FastCompiledTask is like CompiledTask, but it skippes dynamic variables.
 func (e *Executor) FastCompiledTask(call *ast.Call) (*ast.Task, error) {
-	return e.compiledTask(call, false)
+	return e.compiledTask(context.Background(), call, false)
+}
+
+// ResolveVar returns the value of the variable with the given name, as it is
+// resolved when running the task of call. Dynamic variables are evaluated. If
+// call is nil, only the global variables are resolved. Values that are not
+// strings are formatted the same way they are when used in a template.
+func (e *Executor) ResolveVar(call *ast.Call, name string) (string, error) {
+	vars, err := e.resolveVars(call)
+	if err != nil {
+		return "", err
+	}
+
+	if !vars.Exists(name) {
+		return "", fmt.Errorf(`task: Variable "%s" is not defined`, name)
+	}
+	if err := e.Compiler.ResolveLazyVars(context.Background(), vars, name); err != nil {
+		return "", err
+	}
+	return formatVarValue(vars.Get(name).Value), nil
+}
+
+// resolveVars resolves the variables of the task of call, or only the global
+// variables if call is nil. Lazy dynamic variables are left unresolved.
+func (e *Executor) resolveVars(call *ast.Call) (*ast.Vars, error) {
+	if call == nil {
+		return e.Compiler.GetTaskfileVariables()
+	}
+	t, err := e.GetTask(call)
+	if err != nil {
+		return nil, err
+	}
+	return e.Compiler.GetVariables(t, call)
+}
+
+// formatVarValue formats the value of a variable the same way it is when used
+// in a template.
+func formatVarValue(value any) string {
+	switch value := value.(type) {
+	case nil:
+		return ""
+	case string:
+		return value
+	default:
+		return fmt.Sprint(value)
+	}
+}
+
+// ResolvedVar is the final value of a variable and where it comes from.
+type ResolvedVar struct {
+	Value any
+	// Source is where the variable is declared.
+	Source ast.VarSource
+	// Dynamic is true if Value is the output of the command of a dynamic
+	// variable.
+	Dynamic bool
+}
+
+// ResolvedVars returns all the variables of the task of call, resolved as
+// they are when running it, along with where each of them comes from. It is
+// meant for debugging the precedence of variables. If call is nil, only the
+// global variables are resolved. Lazy dynamic variables are resolved too.
+func (e *Executor) ResolvedVars(call *ast.Call) (map[string]ResolvedVar, error) {
+	vars, err := e.resolveVars(call)
+	if err != nil {
+		return nil, err
+	}
+	if err := e.Compiler.ResolveLazyVars(context.Background(), vars, vars.Keys()...); err != nil {
+		return nil, err
+	}
+
+	resolved := make(map[string]ResolvedVar, vars.Len())
+	_ = vars.Range(func(k string, v ast.Var) error {
+		resolved[k] = ResolvedVar{Value: v.Value, Source: v.Source, Dynamic: v.Dynamic}
+		return nil
+	})
+	return resolved, nil
+}
+
+// ValidateVariables checks that the templates of all the variables declared in
+// the Taskfile, Taskvars files, includes and tasks can be parsed, without
+// rendering them or running any command. All the invalid templates are
+// reported, each as an *errors.TemplateError naming the variable and where it
+// is declared.
+func (e *Executor) ValidateVariables() error {
+	cache := &templater.Cache{Funcs: e.Compiler.Funcs}
+	checked := make(map[*ast.Vars]bool)
+	var errs []error
+	check := func(vars *ast.Vars, source string) {
+		if vars == nil || checked[vars] {
+			return
+		}
+		checked[vars] = true
+		_ = vars.Range(func(name string, v ast.Var) error {
+			cache.Delims = cmp.Or(v.Delims, e.Compiler.Delims)
+			err := templater.ParseVar(v, cache)
+			var templateErr *errors.TemplateError
+			if errors.As(err, &templateErr) {
+				templateErr.Var = name
+				templateErr.VarSource = source
+				if v.Source == ast.VarSourceTaskvars {
+					templateErr.VarSource = "a Taskvars file"
+				}
+			}
+			if err != nil {
+				errs = append(errs, err)
+			}
+			return nil
+		})
+	}
+
+	check(e.Taskfile.Env, "the env of the Taskfile")
+	check(e.Taskfile.Vars, "the Taskfile")
+	for _, t := range e.Taskfile.Tasks.Values() {
+		check(t.IncludeVars, "the include of "+t.Location.Taskfile)
+		check(t.IncludedTaskfileVars, t.Location.Taskfile)
+		check(t.Vars, fmt.Sprintf("task %q", t.Task))
+		check(t.Env, fmt.Sprintf("the env of task %q", t.Task))
+	}
+	return errors.Join(errs...)
 }
 
-func (e *Executor) compiledTask(call *ast.Call, evaluateShVars bool) (*ast.Task, error) {
+// taskVarRefRegex matches calls to the taskVar template function with a
+// literal task name.
+var taskVarRefRegex = regexp.MustCompile(`\btaskVar\s+"([^"]+)"`)
+
+// taskVarRef is a variable of a task read with the taskVar function.
+type taskVarRef struct {
+	task, name string
+}
+
+// taskVar returns the implementation of the taskVar template function, which
+// returns the value of a variable of another task, resolved as it is when
+// running that task without variables.
+//
+// The variables being read are passed down to the taskVar functions used while
+// resolving the variables of the task, so that a cycle is reported even when
+// the task names aren't literals, which taskVarCycle can't follow.
+func (e *Executor) taskVar(reading []taskVarRef) func(taskName, name string) (any, error) {
+	return func(taskName, name string) (any, error) {
+		call := &ast.Call{Task: taskName}
+		t, err := e.GetTask(call)
+		if err != nil {
+			return nil, err
+		}
+		if cycle := e.taskVarCycle(t.Task, nil); cycle != nil {
+			return nil, taskVarCycleError(cycle)
+		}
+		ref := taskVarRef{task: t.Task, name: name}
+		if i := slices.Index(reading, ref); i != -1 {
+			var cycle []string
+			for _, r := range append(reading[i:], ref) {
+				cycle = append(cycle, r.task)
+			}
+			return nil, taskVarCycleError(cycle)
+		}
+		funcs := maps.Clone(e.Compiler.Funcs)
+		funcs["taskVar"] = e.taskVar(append(slices.Clone(reading), ref))
+		ctx := compiler.WithFuncs(context.Background(), funcs)
+		vars, err := e.Compiler.GetVariablesContext(ctx, t, call)
+		if err != nil {
+			return nil, err
+		}
+		if !vars.Exists(name) {
+			return nil, fmt.Errorf(`task: Task "%s" has no variable "%s"`, t.Task, name)
+		}
+		if err := e.Compiler.ResolveLazyVars(ctx, vars, name); err != nil {
+			return nil, err
+		}
+		return vars.Get(name).Value, nil
+	}
+}
+
+func taskVarCycleError(cycle []string) error {
+	return fmt.Errorf("task: Tasks reference each other's variables in a cycle: %s", strings.Join(cycle, " -> "))
+}
+
+// taskVarCycle returns a cycle of tasks whose variables reference the
+// variables of the next one using the taskVar function, starting from the
+// given task, or nil if there is none. It only follows calls with a literal
+// task name. Resolving the variables of a task also resolves the global
+// variables, so references made by global variables are followed from every
+// task.
+func (e *Executor) taskVarCycle(taskName string, stack []string) []string {
+	if i := slices.Index(stack, taskName); i != -1 {
+		return append(slices.Clone(stack[i:]), taskName)
+	}
+	t, err := e.GetTask(&ast.Call{Task: taskName})
+	if err != nil {
+		return nil
+	}
+	stack = append(stack, t.Task)
+	for _, vars := range []*ast.Vars{e.Taskfile.Vars, t.ParentTaskfileVars, t.IncludeVars, t.IncludedTaskfileVars, t.Vars} {
+		for _, ref := range taskVarRefs(vars) {
+			if cycle := e.taskVarCycle(ref, stack); cycle != nil {
+				return cycle
+			}
+		}
+	}
+	return nil
+}
+
+// taskVarRefs returns the names of the tasks referenced by the given variables
+// using the taskVar function.
+func taskVarRefs(vars *ast.Vars) []string {
+	var refs []string
+	_ = vars.Range(func(_ string, v ast.Var) error {
+		sources := []string{v.Ref, v.Stdin, v.File, v.Glob, v.Dir}
+		if s, ok := v.Value.(string); ok {
+			sources = append(sources, s)
+		}
+		if v.Sh != nil {
+			sources = append(sources, *v.Sh)
+		}
+		for _, s := range sources {
+			for _, match := range taskVarRefRegex.FindAllStringSubmatch(s, -1) {
+				refs = append(refs, match[1])
+			}
+		}
+		return nil
+	})
+	return refs
+}
+
+// SetVar sets a variable with the highest precedence: it overrides the
+// variables of the Taskfile, its includes, calls and tasks, as well as the
+// environment variables. It must be called after Setup, and is safe to call
+// while tasks are running.
+func (e *Executor) SetVar(name string, v ast.Var) {
+	e.Compiler.SetVar(name, v)
+}
+
+// SetVars sets variables with the highest precedence, like SetVar.
+func (e *Executor) SetVars(vars *ast.Vars) {
+	e.Compiler.SetVars(vars)
+}
+
+func (e *Executor) compiledTask(ctx context.Context, call *ast.Call, evaluateShVars bool) (*ast.Task, error) {
 	origTask, err := e.GetTask(call)
 	if err != nil {
 		return nil, err
@@ -35,7 +283,10 @@ func (e *Executor) compiledTask(call *ast.Call, evaluateShVars bool) (*ast.Task,
 
 	var vars *ast.Vars
 	if evaluateShVars {
-		vars, err = e.Compiler.GetVariables(origTask, call)
+		vars, err = e.Compiler.GetVariablesContext(ctx, origTask, call)
+		if err == nil {
+			err = e.Compiler.ResolveLazyVars(ctx, vars, e.Compiler.LazyVarRefs(vars, origTask)...)
+		}
 	} else {
 		vars, err = e.Compiler.FastGetVariables(origTask, call)
 	}
@@ -43,7 +294,7 @@ func (e *Executor) compiledTask(call *ast.Call, evaluateShVars bool) (*ast.Task,
 		return nil, err
 	}
 
-	cache := &templater.Cache{Vars: vars}
+	cache := &templater.Cache{Vars: vars, Delims: cmp.Or(origTask.Delims, e.Taskfile.Delims), Funcs: e.Compiler.Funcs, Strict: e.StrictTemplates}
 
 	new := ast.Task{
 		Task:                 origTask.Task,
@@ -69,8 +320,10 @@ func (e *Executor) compiledTask(call *ast.Call, evaluateShVars bool) (*ast.Task,
 		Run:                  templater.Replace(origTask.Run, cache),
 		IncludeVars:          origTask.IncludeVars,
 		IncludedTaskfileVars: origTask.IncludedTaskfileVars,
+		ParentTaskfileVars:   origTask.ParentTaskfileVars,
 		Platforms:            origTask.Platforms,
 		Location:             origTask.Location,
+		Delims:               origTask.Delims,
 		Requires:             origTask.Requires,
 		Watch:                origTask.Watch,
 		Namespace:            origTask.Namespace,
@@ -116,7 +369,7 @@ func (e *Executor) compiledTask(call *ast.Call, evaluateShVars bool) (*ast.Task,
 				new.Env.Set(k, ast.Var{Value: v.Value})
 				return nil
 			}
-			static, err := e.Compiler.HandleDynamicVar(v, new.Dir)
+			static, err := e.Compiler.HandleNamedDynamicVar(ctx, k, v, new.Dir, env.FromVars(vars))
 			if err != nil {
 				return err
 			}
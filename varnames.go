@@ -0,0 +1,56 @@
+package task
+
+import (
+	"fmt"
+	"regexp"
+
+	"github.com/go-task/task/v3/errors"
+	"github.com/go-task/task/v3/taskfile/ast"
+)
+
+// varNameRegex matches the names of variables that can be referenced with the
+// dot syntax of templates, e.g. {{.NAME}}: letters, digits and underscores,
+// not starting with a digit.
+var varNameRegex = regexp.MustCompile(`^[\p{L}_][\p{L}\p{Nd}_]*$`)
+
+// checkVarNames warns about variables declared in the Taskfile whose name
+// can't be referenced with the dot syntax of templates when WarnVarNames is
+// set, or returns an error when StrictVarNames is set. Environment variables
+// are not checked, since their names are not chosen in the Taskfile.
+func (e *Executor) checkVarNames() error {
+	if !e.WarnVarNames && !e.StrictVarNames {
+		return nil
+	}
+	seen := make(map[string]bool)
+	check := func(vars *ast.Vars, source string) error {
+		return vars.Range(func(name string, v ast.Var) error {
+			if varNameRegex.MatchString(name) || seen[source+"\x00"+name] {
+				return nil
+			}
+			seen[source+"\x00"+name] = true
+			declaredIn := source
+			if v.Source == ast.VarSourceTaskvars {
+				declaredIn = "a Taskvars file"
+			}
+			msg := fmt.Sprintf(`task: Variable %q of %s can't be referenced as {{.%s}}. Use {{index . %q}} or a name made of letters, digits and underscores`, name, declaredIn, name, name)
+			if e.StrictVarNames {
+				return errors.New(msg)
+			}
+			e.Logger.Warnf("%s\n", msg)
+			return nil
+		})
+	}
+
+	if err := check(e.Taskfile.Vars, "the Taskfile"); err != nil {
+		return err
+	}
+	return e.Taskfile.Tasks.Range(func(name string, t *ast.Task) error {
+		if err := check(t.IncludeVars, "the include of "+t.Location.Taskfile); err != nil {
+			return err
+		}
+		if err := check(t.IncludedTaskfileVars, t.Location.Taskfile); err != nil {
+			return err
+		}
+		return check(t.Vars, fmt.Sprintf("task %q", name))
+	})
+}